@@ -1,49 +1,305 @@
 package app
 
 import (
+	"context"
+	"form3-interview/auth"
 	form3_client "form3-interview/clients"
+	"form3-interview/config"
 	"form3-interview/handlers"
+	"form3-interview/logging"
+	"form3-interview/metrics"
+	"form3-interview/signing"
+	"form3-interview/tracing"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 )
 
 type App struct {
-	Router *mux.Router
-	Client form3_client.Form3ClientIface
+	Router           *mux.Router
+	Client           form3_client.Form3ClientIface
+	IdempotencyStore handlers.IdempotencyStore
+	Logger           *logrus.Logger
+	Metrics          *metrics.Metrics
+	// Registry gathers the metrics served on /metrics.
+	Registry       *prometheus.Registry
+	TracerProvider *sdktrace.TracerProvider
+	// Server serves Router. Its settings come from the config given to NewApp.
+	Server *http.Server
+	// ShutdownTimeout is how long Run waits for in-flight requests once it is
+	// told to stop.
+	ShutdownTimeout time.Duration
+	// Authenticator authenticates the callers of the account routes, allowed
+	// the organisations Principals maps them to; nil lets everyone through.
+	Authenticator auth.Authenticator
+	Principals    auth.Principals
+
+	// certFile and keyFile serve HTTPS when set.
+	certFile string
+	keyFile  string
+
+	listener net.Listener
+	served   chan error
+	// exportsSpans is set when spans are exported, and so need flushing on
+	// shutdown.
+	exportsSpans bool
 }
 
-var app *App
+// NewApp wires the app together from config and registers its routes. It does
+// not listen until Start or Run is called.
+func NewApp(config config.Config) (*App, error) {
+	logger, err := logging.New(config.Log.Level, config.Log.Format)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := form3Transport(config.Form3)
+	if err != nil {
+		return nil, err
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	appMetrics := metrics.New(registry)
+	exporter, err := tracing.NewExporter(config.Tracing.Exporter)
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider := tracing.NewProvider("form3-interview", exporter)
+	client := &form3_client.Form3Client{
+		HttpClient: &http.Client{
+			Timeout:   config.Form3.Timeout,
+			Transport: transport,
+		},
+		BaseURL: config.Form3.BaseURL,
+		Retry: &form3_client.RetryPolicy{
+			MaxAttempts: config.Form3.Retry.MaxAttempts,
+			BaseDelay:   config.Form3.Retry.BaseDelay,
+			MaxDelay:    config.Form3.Retry.MaxDelay,
+		},
+		VersionConflictRetries: config.Form3.VersionConflictRetries,
+		Metrics:                appMetrics,
+		TracerProvider:         tracerProvider,
+	}
+	if len(config.Form3.Signing.KeyID) != 0 {
+		signer, err := signing.NewSigner(config.Form3.Signing.KeyID, config.Form3.Signing.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		// Authorization carries the bearer token when there is one.
+		if config.Form3.Auth.ClientCredentials() || len(config.Form3.Auth.Token) != 0 {
+			signer.SignatureHeader = "Signature"
+		}
+		client.Signer = signer
+	}
+	if config.Form3.CircuitBreaker.FailureThreshold > 0 {
+		client.Breaker = form3_client.NewCircuitBreaker(config.Form3.CircuitBreaker.FailureThreshold, config.Form3.CircuitBreaker.CoolDown)
+	}
+	authenticator, err := inboundAuthenticator(config.Inbound)
+	if err != nil {
+		return nil, err
+	}
+	router := mux.NewRouter().StrictSlash(true)
+	app := &App{
+		Router:           router,
+		Client:           client,
+		IdempotencyStore: handlers.NewMemoryIdempotencyStore(24 * time.Hour),
+		Authenticator:    authenticator,
+		Principals:       config.Inbound.Principals,
+		Logger:           logger,
+		Metrics:          appMetrics,
+		Registry:         registry,
+		TracerProvider:   tracerProvider,
+		Server: &http.Server{
+			Addr:              config.Server.Addr,
+			Handler:           router,
+			ReadTimeout:       config.Server.ReadTimeout,
+			ReadHeaderTimeout: config.Server.ReadHeaderTimeout,
+			WriteTimeout:      config.Server.WriteTimeout,
+			IdleTimeout:       config.Server.IdleTimeout,
+			MaxHeaderBytes:    config.Server.MaxHeaderBytes,
+			ErrorLog:          log.New(logger.WriterLevel(logrus.WarnLevel), "", 0),
+		},
+		ShutdownTimeout: config.Server.ShutdownTimeout,
+		certFile:        config.Server.TLS.CertFile,
+		keyFile:         config.Server.TLS.KeyFile,
+		exportsSpans:    exporter != nil,
+	}
+	app.routes()
+	return app, nil
+}
 
-func NewApp() *App {
-	return &App{
-		Router: mux.NewRouter().StrictSlash(true),
-		Client: &form3_client.Form3Client{
-			HttpClient: &http.Client{
-				Timeout: 5 * time.Second,
+// form3Transport returns the transport of the calls made to form3, with its
+// TLS and authentication settings applied.
+func form3Transport(config config.Form3) (http.RoundTripper, error) {
+	tlsConfig, err := config.TLS.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if tlsConfig != nil {
+		custom := http.DefaultTransport.(*http.Transport).Clone()
+		custom.TLSClientConfig = tlsConfig
+		transport = custom
+	}
+	switch {
+	case config.Auth.ClientCredentials():
+		transport = &form3_client.OAuth2{
+			Source: &form3_client.ClientCredentials{
+				TokenURL:     config.Auth.TokenURL,
+				ClientID:     config.Auth.ClientID,
+				ClientSecret: config.Auth.ClientSecret,
+				Scopes:       strings.Fields(config.Auth.Scopes),
+				HttpClient:   &http.Client{Timeout: config.Timeout, Transport: transport},
 			},
-			BaseURL: getEnv("BASE_URL", "http://localhost:8080/"),
-		},
+			Base: transport,
+		}
+	case len(config.Auth.Token) != 0:
+		transport = &form3_client.BearerToken{Token: config.Auth.Token, Base: transport}
+	}
+	return transport, nil
+}
+
+// inboundAuthenticator returns the authenticator of the credentials config
+// sets up, or nil when there are none.
+func inboundAuthenticator(config config.Inbound) (auth.Authenticator, error) {
+	var chain auth.Chain
+	if len(config.APIKeys) != 0 {
+		keys := map[string]string{}
+		for _, key := range config.APIKeys {
+			keys[key.Key] = key.Principal
+		}
+		chain = append(chain, auth.NewAPIKeys(keys))
+	}
+	if len(config.HMACKeys) != 0 {
+		keys := map[string]auth.HMACKey{}
+		for _, key := range config.HMACKeys {
+			keys[key.ID] = auth.HMACKey{Principal: key.Principal, Secret: []byte(key.Secret)}
+		}
+		chain = append(chain, &auth.HMAC{Keys: keys})
 	}
+	if len(config.JWT.JWKSFile) != 0 {
+		jwt, err := auth.NewJWT(config.JWT.JWKSFile, config.JWT.Issuer, config.JWT.Audience)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwt)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+func (a *App) routes() {
+	observe := []mux.MiddlewareFunc{handlers.Tracing(a.TracerProvider), handlers.RequestLogging(a.Logger), handlers.RequestMetrics(a.Metrics)}
+	a.Router.Use(observe...)
+	// The router only runs its middlewares on requests matching a route, so
+	// the handlers answering the others are wrapped in them too.
+	a.Router.NotFoundHandler = chain(http.NotFoundHandler(), observe)
+	a.Router.MethodNotAllowedHandler = chain(http.HandlerFunc(methodNotAllowed), observe)
+	a.Router.HandleFunc("/healthz", handlers.Liveness()).Methods(http.MethodGet)
+	a.Router.HandleFunc("/readyz", handlers.Readiness(a.Client, 2*time.Second, 5*time.Second)).Methods(http.MethodGet)
+	a.Router.Handle("/metrics", promhttp.HandlerFor(a.Registry, promhttp.HandlerOpts{})).Methods(http.MethodGet)
+
+	accounts := a.Router.PathPrefix("/form3Client/accounts").Subrouter()
+	if a.Authenticator != nil {
+		accounts.Use(handlers.Authentication(a.Authenticator, a.Principals))
+	}
+	accounts.HandleFunc("/{accountId}", handlers.GetAccount(a.Client)).Methods(http.MethodGet)
+	accounts.HandleFunc("", handlers.ListAccounts(a.Client)).Methods(http.MethodGet)
+	accounts.HandleFunc("", handlers.Idempotent(a.IdempotencyStore, handlers.CreateAccount(a.Client))).Methods(http.MethodPost)
+	accounts.HandleFunc("/{accountId}", handlers.DeleteAccount(a.Client)).Methods(http.MethodDelete)
+	accounts.HandleFunc("/{accountId}", handlers.UpdateAccount(a.Client)).Methods(http.MethodPatch)
+}
+
+// chain wraps handler in middlewares, the first one outermost, as Router.Use
+// does.
+func chain(handler http.Handler, middlewares []mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
 }
 
-func getEnv(key, fallback string) string {
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return fallback
+// Start listens on the configured address and serves in the background. It
+// returns once the listener is bound, so requests can be sent to Addr right
+// away.
+func (a *App) Start() error {
+	listener, err := net.Listen("tcp", a.Server.Addr)
+	if err != nil {
+		return errors.Wrap(err, "Could not listen on "+a.Server.Addr)
 	}
-	return value
+	a.listener = listener
+	a.served = make(chan error, 1)
+	a.Logger.WithField("address", listener.Addr().String()).Info("Starting server")
+	go func() {
+		if len(a.certFile) != 0 {
+			a.served <- a.Server.ServeTLS(listener, a.certFile, a.keyFile)
+			return
+		}
+		a.served <- a.Server.Serve(listener)
+	}()
+	return nil
 }
 
-func (a *App) HandleRequests() {
-	if app == nil {
-		app = NewApp()
+// Addr returns the address the app listens on once started, with the port
+// picked when the configured one is 0.
+func (a *App) Addr() string {
+	if a.listener == nil {
+		return a.Server.Addr
 	}
-	log.Println("inside app")
-	app.Router.HandleFunc("/form3Client/accounts/{accountId}", handlers.GetAccount(app.Client)).Methods(http.MethodGet)
-	app.Router.HandleFunc("/form3Client/accounts", handlers.CreateAccount(app.Client)).Methods(http.MethodPost)
-	app.Router.HandleFunc("/form3Client/accounts/{accountId}", handlers.DeleteAccount(app.Client)).Methods(http.MethodDelete)
-	log.Fatal(http.ListenAndServe(":8081", app.Router))
+	return a.listener.Addr().String()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// complete, or for ctx to be done, whichever comes first. Pending spans are
+// flushed before it returns.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.Logger.Info("Shutting down server")
+	if err := a.Server.Shutdown(ctx); err != nil {
+		return errors.Wrap(err, "Could not drain in-flight requests")
+	}
+	if a.exportsSpans {
+		if err := a.TracerProvider.Shutdown(ctx); err != nil {
+			return errors.Wrap(err, "Could not flush spans")
+		}
+	}
+	return nil
+}
+
+// Run starts the app and blocks until it receives SIGINT or SIGTERM, then
+// shuts it down gracefully, giving in-flight requests ShutdownTimeout to
+// complete.
+func (a *App) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := a.Start(); err != nil {
+		return err
+	}
+	select {
+	case err := <-a.served:
+		return errors.Wrap(err, "Server stopped")
+	case received := <-signals:
+		a.Logger.WithField("signal", received.String()).Info("Received signal")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout)
+	defer cancel()
+	return a.Shutdown(ctx)
 }
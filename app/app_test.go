@@ -0,0 +1,172 @@
+package app_test
+
+import (
+	"context"
+	"form3-interview/app"
+	"form3-interview/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestApp(t *testing.T) *app.App {
+	appConfig := config.Default()
+	appConfig.Server.Addr = "127.0.0.1:0"
+	a, err := app.NewApp(appConfig)
+	require.NoError(t, err)
+	a.Logger.SetOutput(ioutil.Discard)
+	return a
+}
+
+// slowRoute registers a route that blocks until release is closed, signalling
+// on started once it is handling a request.
+func slowRoute(a *app.App) (started chan struct{}, release chan struct{}) {
+	started, release = make(chan struct{}, 1), make(chan struct{})
+	a.Router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	return started, release
+}
+
+func Test_appStartServesRequests(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t)
+	require.NoError(t, a.Start())
+	defer a.Shutdown(context.Background())
+
+	res, err := http.Get("http://" + a.Addr() + "/healthz")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, res.Header.Get("X-Request-ID"))
+}
+
+func Test_appShutdownDrainsInFlightRequests(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t)
+	started, release := slowRoute(a)
+	require.NoError(t, a.Start())
+	addr := a.Addr()
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- a.Shutdown(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		_, err := http.Get("http://" + addr + "/healthz")
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "new connections are refused while draining")
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-status)
+	assert.NoError(t, <-shutdown)
+}
+
+func Test_appShutdownGivesUpAtDeadline(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t)
+	started, release := slowRoute(a)
+	defer close(release)
+	require.NoError(t, a.Start())
+
+	go http.Get("http://" + a.Addr() + "/slow")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := a.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+func Test_appAuthenticatesCallsToForm3(t *testing.T) {
+	t.Parallel()
+
+	authorization := make(chan string, 1)
+	form3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		w.Write([]byte(`{"status":"up"}`))
+	}))
+	defer form3.Close()
+
+	appConfig := config.Default()
+	appConfig.Form3.BaseURL = form3.URL + "/"
+	appConfig.Form3.Auth.Token = "s3cr3t"
+	a, err := app.NewApp(appConfig)
+	require.NoError(t, err)
+
+	_, err = a.Client.Health(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer s3cr3t", <-authorization)
+}
+
+func Test_appAuthenticatesCallers(t *testing.T) {
+	t.Parallel()
+
+	appConfig := config.Default()
+	appConfig.Server.Addr = "127.0.0.1:0"
+	appConfig.Inbound.APIKeys = []config.APIKey{{Key: "k3y", Principal: "acme"}}
+	appConfig.Inbound.Principals = map[string][]string{"acme": {"eb0bd6f5-c3f5-44b2-b677-acd23cdde73c"}}
+	a, err := app.NewApp(appConfig)
+	require.NoError(t, err)
+	a.Logger.SetOutput(ioutil.Discard)
+	require.NoError(t, a.Start())
+	defer a.Shutdown(context.Background())
+
+	for path, status := range map[string]int{
+		"/form3Client/accounts":      http.StatusUnauthorized,
+		"/form3Client/accounts/1234": http.StatusUnauthorized,
+		"/healthz":                   http.StatusOK,
+	} {
+		res, err := http.Get("http://" + a.Addr() + path)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, status, res.StatusCode, path)
+	}
+}
+
+func Test_appRecordsUnmatchedRequests(t *testing.T) {
+	t.Parallel()
+
+	a := newTestApp(t)
+	require.NoError(t, a.Start())
+	defer a.Shutdown(context.Background())
+
+	for method, path := range map[string]string{http.MethodGet: "/nope", http.MethodDelete: "/healthz"} {
+		req, err := http.NewRequest(method, "http://"+a.Addr()+path, nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.NotEmpty(t, res.Header.Get("X-Request-ID"), path)
+	}
+
+	res, err := http.Get("http://" + a.Addr() + "/metrics")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `form3_proxy_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, string(body), `form3_proxy_requests_total{method="DELETE",route="unmatched",status="405"} 1`)
+}
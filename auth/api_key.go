@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// APIKeyHeader carries the API key of a request.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by the static API key they carry in the
+// X-API-Key header.
+type APIKeys struct {
+	// principals is keyed by the hash of each key, so that looking a key up
+	// takes the same time however much of it is right.
+	principals map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys returns an authenticator of the keys, each mapped to the name of
+// its principal.
+func NewAPIKeys(keys map[string]string) *APIKeys {
+	principals := make(map[[sha256.Size]byte]string, len(keys))
+	for key, principal := range keys {
+		principals[sha256.Sum256([]byte(key))] = principal
+	}
+	return &APIKeys{principals: principals}
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (string, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if len(key) == 0 {
+		return "", ErrNoCredentials
+	}
+	principal, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return "", errors.Wrap(ErrInvalidCredentials, "unknown API key")
+	}
+	return principal, nil
+}
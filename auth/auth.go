@@ -0,0 +1,104 @@
+// Package auth authenticates the callers of the proxy, with static API keys,
+// HMAC signed requests or JWT bearer tokens, and tells which organisations
+// each caller may act on.
+package auth
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials is wrapped by the error of an Authenticator finding
+	// none of the credentials it accepts on a request.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is wrapped by the error of an Authenticator
+	// rejecting the credentials of a request.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// AnyOrganisation in the organisations of a principal allows all of them.
+const AnyOrganisation = "*"
+
+// Authenticator tells who sent a request.
+type Authenticator interface {
+	// Authenticate returns the name of the principal that sent r, or an
+	// error wrapping ErrNoCredentials or ErrInvalidCredentials.
+	Authenticate(r *http.Request) (string, error)
+}
+
+// Chain authenticates a request with the first of its authenticators finding
+// credentials on it.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (string, error) {
+	for _, authenticator := range c {
+		name, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return name, err
+	}
+	return "", ErrNoCredentials
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name string
+	// Organisations are the ids of the organisations the principal may act
+	// on; AnyOrganisation allows all of them.
+	Organisations []string
+}
+
+// Allows reports whether the principal may act on the organisation.
+func (p Principal) Allows(organisationID string) bool {
+	for _, allowed := range p.Organisations {
+		if allowed == AnyOrganisation || allowed == organisationID {
+			return true
+		}
+	}
+	return false
+}
+
+// Unrestricted reports whether the principal may act on every organisation.
+func (p Principal) Unrestricted() bool {
+	return p.Allows(AnyOrganisation)
+}
+
+// Principals maps the name of each principal to the organisations it may act
+// on.
+type Principals map[string][]string
+
+// Principal returns the principal called name, allowed no organisation when
+// it is not mapped.
+func (p Principals) Principal(name string) Principal {
+	return Principal{Name: name, Organisations: p[name]}
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal carried by ctx.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Restricted reports whether the caller of ctx may only act on some
+// organisations. Requests that were not authenticated, because inbound
+// authentication is disabled, are not restricted.
+func Restricted(ctx context.Context) bool {
+	principal, ok := FromContext(ctx)
+	return ok && !principal.Unrestricted()
+}
+
+// Allowed reports whether the caller of ctx may act on the organisation.
+func Allowed(ctx context.Context, organisationID string) bool {
+	principal, ok := FromContext(ctx)
+	return !ok || principal.Allows(organisationID)
+}
@@ -0,0 +1,273 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"form3-interview/auth"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_principalAllows(t *testing.T) {
+	t.Parallel()
+
+	principals := auth.Principals{
+		"acme":  {"org-1", "org-2"},
+		"admin": {auth.AnyOrganisation},
+	}
+	assert.True(t, principals.Principal("acme").Allows("org-2"))
+	assert.False(t, principals.Principal("acme").Allows("org-3"))
+	assert.False(t, principals.Principal("acme").Unrestricted())
+	assert.True(t, principals.Principal("admin").Allows("org-3"))
+	assert.True(t, principals.Principal("admin").Unrestricted())
+	assert.False(t, principals.Principal("unknown").Allows("org-1"))
+}
+
+func Test_apiKeys(t *testing.T) {
+	t.Parallel()
+
+	keys := auth.NewAPIKeys(map[string]string{"k3y": "acme"})
+	testCases := []struct {
+		name     string
+		key      string
+		expected string
+		err      error
+	}{
+		{name: "known key", key: "k3y", expected: "acme"},
+		{name: "unknown key", key: "k3y2", err: auth.ErrInvalidCredentials},
+		{name: "no key", err: auth.ErrNoCredentials},
+	}
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("GET", "/form3Client/accounts", nil)
+			if len(test.key) != 0 {
+				req.Header.Set(auth.APIKeyHeader, test.key)
+			}
+			principal, err := keys.Authenticate(req)
+			assert.True(t, errors.Is(err, test.err), "got %v", err)
+			assert.Equal(t, test.expected, principal)
+		})
+	}
+}
+
+func Test_hmac(t *testing.T) {
+	t.Parallel()
+
+	authenticator := &auth.HMAC{Keys: map[string]auth.HMACKey{"acme-1": {Principal: "acme", Secret: []byte("s3cr3t")}}}
+	testCases := []struct {
+		name     string
+		sign     func(req *http.Request)
+		expected string
+		err      error
+	}{
+		{
+			name:     "signed request",
+			sign:     func(req *http.Request) { auth.SignHMAC(req, "acme-1", []byte("s3cr3t")) },
+			expected: "acme",
+		},
+		{
+			name: "wrong secret",
+			sign: func(req *http.Request) { auth.SignHMAC(req, "acme-1", []byte("guess")) },
+			err:  auth.ErrInvalidCredentials,
+		},
+		{
+			name: "unknown key",
+			sign: func(req *http.Request) { auth.SignHMAC(req, "acme-2", []byte("s3cr3t")) },
+			err:  auth.ErrInvalidCredentials,
+		},
+		{
+			name: "tampered body",
+			sign: func(req *http.Request) {
+				auth.SignHMAC(req, "acme-1", []byte("s3cr3t"))
+				req.Body = ioutil.NopCloser(strings.NewReader(`{"data":{"organisation_id":"other"}}`))
+			},
+			err: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "stale timestamp",
+			sign: func(req *http.Request) {
+				timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+				bodyHash := sha256.Sum256([]byte(`{"data":{}}`))
+				mac := hmac.New(sha256.New, []byte("s3cr3t"))
+				mac.Write([]byte("POST\n/form3Client/accounts?x=1\n" + timestamp + "\n" + base64.StdEncoding.EncodeToString(bodyHash[:]) + "\n"))
+				req.Header.Set("Authorization", `HMAC-SHA256 keyId="acme-1",timestamp="`+timestamp+`",signature="`+base64.StdEncoding.EncodeToString(mac.Sum(nil))+`"`)
+			},
+			err: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "not signed",
+			sign: func(req *http.Request) {},
+			err:  auth.ErrNoCredentials,
+		},
+	}
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("POST", "/form3Client/accounts?x=1", strings.NewReader(`{"data":{}}`))
+			test.sign(req)
+			principal, err := authenticator.Authenticate(req)
+			assert.True(t, errors.Is(err, test.err), "got %v", err)
+			assert.Equal(t, test.expected, principal)
+			if err == nil {
+				body, _ := ioutil.ReadAll(req.Body)
+				assert.Equal(t, `{"data":{}}`, string(body), "the body is left to be read")
+			}
+		})
+	}
+}
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	content, err := json.Marshal(value)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// newJWT returns a token of the claims signed with key under the alg header,
+// hashed with SHA-384 or SHA-512 when alg ends with 384 or 512, SHA-256
+// otherwise.
+func newJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	hash := crypto.SHA256
+	if strings.HasSuffix(alg, "384") {
+		hash = crypto.SHA384
+	} else if strings.HasSuffix(alg, "512") {
+		hash = crypto.SHA512
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	hashed := hasher.Sum(nil)
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, hashed)
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, hashed)
+		require.NoError(t, err)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey) string {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+	}}
+	content, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+	return path
+}
+
+func Test_jwt(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	authenticator, err := auth.NewJWT(writeJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey), "https://issuer.example.com", "form3-proxy")
+	require.NoError(t, err)
+	assert.Len(t, authenticator.Keys, 2, "encryption keys are skipped")
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		base := map[string]interface{}{
+			"sub": "acme",
+			"iss": "https://issuer.example.com",
+			"aud": []string{"other", "form3-proxy"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for key, value := range changes {
+			if value == nil {
+				delete(base, key)
+			} else {
+				base[key] = value
+			}
+		}
+		return base
+	}
+	testCases := []struct {
+		name     string
+		token    string
+		expected string
+		err      error
+	}{
+		{name: "RS256", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(nil)), expected: "acme"},
+		{name: "ES256", token: newJWT(t, "ES256", "ec-1", ecKey, claims(map[string]interface{}{"aud": "form3-proxy"})), expected: "acme"},
+		{name: "expired", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), err: auth.ErrInvalidCredentials},
+		{name: "without expiry", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"exp": nil})), err: auth.ErrInvalidCredentials},
+		{name: "not valid yet", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})), err: auth.ErrInvalidCredentials},
+		{name: "other issuer", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})), err: auth.ErrInvalidCredentials},
+		{name: "other audience", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"aud": "other"})), err: auth.ErrInvalidCredentials},
+		{name: "no subject", token: newJWT(t, "RS256", "rsa-1", rsaKey, claims(map[string]interface{}{"sub": nil})), err: auth.ErrInvalidCredentials},
+		{name: "unknown key", token: newJWT(t, "RS256", "rsa-2", rsaKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "algorithm not matching key", token: newJWT(t, "ES256", "rsa-1", rsaKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "RS384", token: newJWT(t, "RS384", "rsa-1", rsaKey, claims(nil)), expected: "acme"},
+		{name: "algorithm mixing RS and ES", token: newJWT(t, "RSE256", "rsa-1", rsaKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "algorithm with letters swapped", token: newJWT(t, "SR256", "rsa-1", rsaKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "algorithm with letters repeated", token: newJWT(t, "EEES256", "ec-1", ecKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "ECDSA hash not matching curve", token: newJWT(t, "ES384", "ec-1", ecKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "signed by another key", token: newJWT(t, "ES256", "rsa-1", ecKey, claims(nil)), err: auth.ErrInvalidCredentials},
+		{name: "unsigned", token: encodeSegment(t, map[string]string{"alg": "none", "kid": "rsa-1"}) + "." + encodeSegment(t, claims(nil)) + ".", err: auth.ErrInvalidCredentials},
+		{name: "malformed", token: "not.a-jwt", err: auth.ErrInvalidCredentials},
+	}
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("GET", "/form3Client/accounts", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			principal, err := authenticator.Authenticate(req)
+			assert.True(t, errors.Is(err, test.err), "got %v", err)
+			assert.Equal(t, test.expected, principal)
+		})
+	}
+}
+
+func Test_chain(t *testing.T) {
+	t.Parallel()
+
+	chain := auth.Chain{
+		auth.NewAPIKeys(map[string]string{"k3y": "acme"}),
+		&auth.HMAC{Keys: map[string]auth.HMACKey{"globex-1": {Principal: "globex", Secret: []byte("s3cr3t")}}},
+	}
+
+	req := httptest.NewRequest("GET", "/form3Client/accounts", nil)
+	require.NoError(t, auth.SignHMAC(req, "globex-1", []byte("s3cr3t")))
+	principal, err := chain.Authenticate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", principal)
+
+	req.Header.Set(auth.APIKeyHeader, "wrong")
+	_, err = chain.Authenticate(req)
+	assert.True(t, errors.Is(err, auth.ErrInvalidCredentials), "invalid credentials are not passed over")
+
+	_, err = chain.Authenticate(httptest.NewRequest("GET", "/form3Client/accounts", nil))
+	assert.True(t, errors.Is(err, auth.ErrNoCredentials))
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWT authenticates requests by the JWT bearer token they carry, signed with
+// one of the keys of a JWKS. The subject of the token names its principal.
+type JWT struct {
+	// Keys holds the public key of each key id.
+	Keys map[string]crypto.PublicKey
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking exp and nbf; 0 allows a
+	// minute.
+	Leeway time.Duration
+}
+
+// NewJWT returns an authenticator of the tokens signed with the keys of the
+// JWKS file at path.
+func NewJWT(path, issuer, audience string) (*JWT, error) {
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JWT{Keys: keys, Issuer: issuer, Audience: audience}, nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// audience is the aud claim, either a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (j *JWT) Authenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return "", ErrNoCredentials
+	}
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		return "", errors.Wrap(ErrInvalidCredentials, "malformed JWT")
+	}
+	var (
+		header jwtHeader
+		claims jwtClaims
+	)
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", errors.Wrap(ErrInvalidCredentials, "malformed JWT header")
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", errors.Wrap(ErrInvalidCredentials, "malformed JWT claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidCredentials, "malformed JWT signature")
+	}
+	key, ok := j.Keys[header.KeyID]
+	if !ok {
+		return "", errors.Wrapf(ErrInvalidCredentials, "unknown JWT key id %q", header.KeyID)
+	}
+	if err = verifyJWS(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return "", err
+	}
+
+	leeway := j.Leeway
+	if leeway == 0 {
+		leeway = time.Minute
+	}
+	now := time.Now()
+	if claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(leeway)) {
+		return "", errors.Wrap(ErrInvalidCredentials, "JWT expired")
+	}
+	if claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-leeway)) {
+		return "", errors.Wrap(ErrInvalidCredentials, "JWT not valid yet")
+	}
+	if len(j.Issuer) != 0 && claims.Issuer != j.Issuer {
+		return "", errors.Wrapf(ErrInvalidCredentials, "unexpected JWT issuer %q", claims.Issuer)
+	}
+	if len(j.Audience) != 0 && !contains(claims.Audience, j.Audience) {
+		return "", errors.Wrap(ErrInvalidCredentials, "JWT is not meant for this audience")
+	}
+	if len(claims.Subject) == 0 {
+		return "", errors.Wrap(ErrInvalidCredentials, "JWT has no subject")
+	}
+	return claims.Subject, nil
+}
+
+var (
+	// jwsHashes maps the algorithms accepted to the hash they sign with.
+	jwsHashes = map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	}
+	// jwsCurves maps the ECDSA algorithms to the curve of their keys.
+	jwsCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+)
+
+// verifyJWS checks the signature of a JWT with key, the algorithm having to
+// match the type of the key, and its curve for ECDSA, so that a token cannot
+// pick a weaker one.
+func verifyJWS(algorithm string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := jwsHashes[algorithm]
+	if !ok {
+		return errors.Wrapf(ErrInvalidCredentials, "unsupported JWT algorithm %q", algorithm)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(algorithm, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if jwsCurves[algorithm] == key.Curve.Params().Name && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.Wrap(ErrInvalidCredentials, "JWT signature does not match")
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, value)
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// LoadJWKS reads the RSA and EC public keys of a JWKS file, keyed by their
+// key ids. Keys that are not meant for signatures are skipped.
+func LoadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read JWKS file")
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(content, &jwks); err != nil {
+		return nil, errors.Wrapf(err, "Could not parse JWKS file %q", path)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, key := range jwks.Keys {
+		if len(key.Use) != 0 && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid key %q in JWKS file %q", key.KeyID, path)
+		}
+		keys[key.KeyID] = publicKey
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("No signing key found in JWKS file %q", path)
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "malformed modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("malformed exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "malformed x coordinate")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "malformed y coordinate")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, errors.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
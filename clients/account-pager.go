@@ -0,0 +1,92 @@
+package form3_client
+
+import (
+	"context"
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"net/url"
+)
+
+// AccountPager walks every page of a list request, following the links.next
+// URL returned by form3. Pages are fetched lazily, so only one page is held in
+// memory at a time.
+//
+//	pager := NewAccountPager(ctx, client, ListOptions{PageSize: 100})
+//	for pager.Next() {
+//		account := pager.Account()
+//	}
+//	if err := pager.Err(); err != nil {
+//		// the walk stopped early
+//	}
+type AccountPager struct {
+	ctx     context.Context
+	client  Form3Client
+	nextUrl string
+	page    []models.AccountData
+	current models.AccountData
+	err     error
+}
+
+// NewAccountPager prepares a walk over the accounts matching options. Every
+// page is fetched with ctx, so cancelling it stops the walk.
+func NewAccountPager(ctx context.Context, client Form3Client, options ListOptions) *AccountPager {
+	return &AccountPager{
+		ctx:     ctx,
+		client:  client,
+		nextUrl: client.BaseURL + pathUrl + "?" + options.encode(),
+	}
+}
+
+// Next advances to the next account, fetching the following page when the
+// current one is exhausted. It returns false once the last page has been read
+// or when a request fails, in which case Err reports the failure.
+func (p *AccountPager) Next() bool {
+	for len(p.page) == 0 {
+		if p.err != nil || len(p.nextUrl) == 0 {
+			return false
+		}
+		currentUrl := p.nextUrl
+		page, err := p.client.listAccountsPage(p.ctx, currentUrl)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.page = page.Accounts
+		p.nextUrl = ""
+		if len(page.Accounts) != 0 && len(page.Links.Next) != 0 {
+			if p.nextUrl, err = p.resolve(page.Links.Next); err != nil {
+				p.err = err
+				return false
+			}
+			if p.nextUrl == currentUrl {
+				p.nextUrl = ""
+			}
+		}
+	}
+	p.current, p.page = p.page[0], p.page[1:]
+	return true
+}
+
+// Account returns the account the pager is positioned on.
+func (p *AccountPager) Account() models.AccountData {
+	return p.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *AccountPager) Err() error {
+	return p.err
+}
+
+// resolve turns the links.next value, usually a path relative to the API
+// root, into an absolute URL against the client's BaseURL.
+func (p *AccountPager) resolve(next string) (string, error) {
+	base, err := url.Parse(p.client.BaseURL)
+	if err != nil {
+		return "", models.NewAppError(err, "Malfunctioned http client request", 500)
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", models.NewAppError(errors.Wrap(err, next), "Unable to follow the next page link from form3 client", 500)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
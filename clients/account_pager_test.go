@@ -0,0 +1,98 @@
+package form3_client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	form3_client "form3-interview/clients"
+	"form3-interview/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func Test_accountPager(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		pages       [][]string
+		failOnPage  int
+		expectedIds []string
+		err         string
+	}{
+		{
+			name:        "no accounts",
+			pages:       [][]string{{}},
+			failOnPage:  -1,
+			expectedIds: nil,
+		},
+		{
+			name:        "single page",
+			pages:       [][]string{{"a", "b"}},
+			failOnPage:  -1,
+			expectedIds: []string{"a", "b"},
+		},
+		{
+			name:        "walks every page",
+			pages:       [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			failOnPage:  -1,
+			expectedIds: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:        "error in the middle of the stream",
+			pages:       [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			failOnPage:  1,
+			expectedIds: []string{"a", "b"},
+			err:         "Validation error",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			requested := 0
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				requested++
+				pageNumber, _ := strconv.Atoi(req.URL.Query().Get("page[number]"))
+				assert.Equal(t, "2", req.URL.Query().Get("page[size]"))
+				if pageNumber == test.failOnPage {
+					res.WriteHeader(500)
+					res.Write([]byte("{\"error_message\":\"boom\"}"))
+					return
+				}
+				var page models.AccountListWrapper
+				for _, id := range test.pages[pageNumber] {
+					page.Accounts = append(page.Accounts, models.AccountData{ID: id})
+				}
+				if pageNumber+1 < len(test.pages) {
+					page.Links.Next = fmt.Sprintf("/v1/organisation/accounts?page[number]=%d&page[size]=2", pageNumber+1)
+				}
+				json.NewEncoder(res).Encode(page)
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+			pager := form3_client.NewAccountPager(context.Background(), client, form3_client.ListOptions{PageSize: 2})
+
+			var ids []string
+			for pager.Next() {
+				ids = append(ids, pager.Account().ID)
+				assert.LessOrEqual(t, requested, len(ids)/2+1, "pages must be fetched lazily")
+			}
+			assert.False(t, pager.Next())
+			assert.Equal(t, test.expectedIds, ids)
+			if len(test.err) == 0 {
+				assert.NoError(t, pager.Err())
+			} else {
+				assert.Equal(t, test.err, appErrorOf(t, pager.Err()).Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package form3_client
+
+import (
+	"context"
+	"encoding/json"
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BearerToken is an http.RoundTripper authenticating every request it sends
+// with a static bearer token.
+type BearerToken struct {
+	Token string
+	// Base sends the authenticated requests; nil uses http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *BearerToken) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// A RoundTripper must not modify the request it is given.
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("Authorization", "Bearer "+t.Token)
+	return base.RoundTrip(authenticated)
+}
+
+// ClientCredentials fetches access tokens from an OAuth2 token endpoint with
+// the client credentials grant, and caches each one until shortly before it
+// expires. It is safe for concurrent use and must be shared by pointer;
+// concurrent callers needing a new token wait for a single fetch.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshBefore is how long before its expiry a token is replaced; 0
+	// refreshes tokens a minute before they expire.
+	RefreshBefore time.Duration
+	// HttpClient calls the token endpoint; nil uses http.DefaultClient.
+	HttpClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns the cached access token, fetching a new one when there is
+// none or it is about to expire.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	refreshBefore := c.RefreshBefore
+	if refreshBefore == 0 {
+		refreshBefore = time.Minute
+	}
+	if len(c.token) != 0 && (c.expiry.IsZero() || time.Now().Add(refreshBefore).Before(c.expiry)) {
+		return c.token, nil
+	}
+	token, expiry, err := c.fetch(ctx)
+	if err != nil {
+		return "", models.NewAppError(err, "Could not fetch form3 access token", http.StatusBadGateway)
+	}
+	c.token, c.expiry = token, expiry
+	return token, nil
+}
+
+// Invalidate drops token from the cache, so that the next call to Token
+// fetches a new one. A token already replaced is left alone.
+func (c *ClientCredentials) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token, c.expiry = "", time.Time{}
+	}
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (token string, expiry time.Time, err error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) != 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", expiry, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	requested := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", expiry, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", expiry, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthError struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		json.Unmarshal(body, &oauthError)
+		return "", expiry, errors.Errorf("token endpoint answered %d %s %s", resp.StatusCode, oauthError.Error, oauthError.ErrorDescription)
+	}
+	var response tokenResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return "", expiry, errors.Wrap(err, "Could not decode token response")
+	}
+	if len(response.AccessToken) == 0 {
+		return "", expiry, errors.New("token response carries no access_token")
+	}
+	if len(response.TokenType) != 0 && !strings.EqualFold(response.TokenType, "bearer") {
+		return "", expiry, errors.Errorf("unsupported token type %q", response.TokenType)
+	}
+	if response.ExpiresIn > 0 {
+		// Counted from the request, so that the token is never held past its
+		// expiry however slow the response was.
+		expiry = requested.Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return response.AccessToken, expiry, nil
+}
+
+// OAuth2 is an http.RoundTripper authenticating every request it sends with a
+// bearer token of Source. A request answered 401 Unauthorized is sent once
+// more with a freshly fetched token, in case the one it carried was revoked
+// before its expiry.
+type OAuth2 struct {
+	Source *ClientCredentials
+	// Base sends the authenticated requests; nil uses http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *OAuth2) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	resp, err := base.RoundTrip(withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	t.Source.Invalidate(token)
+	// A consumed body can only be sent again when it can be rewound.
+	retry := req
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		retry = req.Clone(req.Context())
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	if token, err = t.Source.Token(req.Context()); err != nil {
+		return resp, nil
+	}
+	drain(resp)
+	return base.RoundTrip(withToken(retry, token))
+}
+
+// withToken returns a copy of req carrying token; a RoundTripper must not
+// modify the request it is given.
+func withToken(req *http.Request, token string) *http.Request {
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("Authorization", "Bearer "+token)
+	return authenticated
+}
+
+// closeBody closes the body of a request that will not be sent, as a
+// RoundTripper must even when it fails.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
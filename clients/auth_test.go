@@ -0,0 +1,212 @@
+package form3_client_test
+
+import (
+	"context"
+	"fmt"
+	form3_client "form3-interview/clients"
+	"form3-interview/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenEndpoint issues the tokens "token-1", "token-2", ... valid for
+// expiresIn seconds, counting how many it issued.
+func tokenEndpoint(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"Bad client credentials"}`))
+			return
+		}
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "accounts:read accounts:write", r.PostForm.Get("scope"))
+		// Slow enough for concurrent callers to pile up behind the fetch.
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, atomic.AddInt32(issued, 1), expiresIn)
+	}))
+}
+
+func Test_clientCredentialsToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		expiresIn     int
+		refreshBefore time.Duration
+		expected      []string
+	}{
+		{
+			name:          "cached until shortly before expiry",
+			expiresIn:     3600,
+			refreshBefore: time.Minute,
+			expected:      []string{"token-1", "token-1", "token-1"},
+		},
+		{
+			name:          "refreshed once about to expire",
+			expiresIn:     3600,
+			refreshBefore: time.Hour,
+			expected:      []string{"token-1", "token-2", "token-3"},
+		},
+		{
+			name:     "cached for good without expiry",
+			expected: []string{"token-1", "token-1", "token-1"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var issued int32
+			server := tokenEndpoint(t, test.expiresIn, &issued)
+			defer server.Close()
+
+			source := &form3_client.ClientCredentials{
+				TokenURL:      server.URL,
+				ClientID:      "client",
+				ClientSecret:  "s3cr3t",
+				Scopes:        []string{"accounts:read", "accounts:write"},
+				RefreshBefore: test.refreshBefore,
+			}
+			for _, expected := range test.expected {
+				token, err := source.Token(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, expected, token)
+			}
+		})
+	}
+}
+
+func Test_clientCredentialsFailure(t *testing.T) {
+	t.Parallel()
+
+	var issued int32
+	server := tokenEndpoint(t, 3600, &issued)
+	defer server.Close()
+
+	source := &form3_client.ClientCredentials{TokenURL: server.URL, ClientID: "client", ClientSecret: "wrong"}
+	_, err := source.Token(context.Background())
+	assert.EqualError(t, err, "Could not fetch form3 access token: token endpoint answered 401 invalid_client Bad client credentials")
+	assert.Equal(t, http.StatusBadGateway, models.StatusCode(err))
+}
+
+func Test_oauth2Transport(t *testing.T) {
+	t.Parallel()
+
+	var issued int32
+	tokens := tokenEndpoint(t, 3600, &issued)
+	defer tokens.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	client := &http.Client{Transport: &form3_client.OAuth2{
+		Source: &form3_client.ClientCredentials{
+			TokenURL:     tokens.URL,
+			ClientID:     "client",
+			ClientSecret: "s3cr3t",
+			Scopes:       []string{"accounts:read", "accounts:write"},
+		},
+	}}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(api.URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued), "concurrent requests share a single token")
+}
+
+func Test_oauth2TransportRetriesUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		accepted       string
+		expectedStatus int
+		expectedCalls  int32
+	}{
+		{
+			name:           "revoked token replaced",
+			accepted:       "Bearer token-2",
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  2,
+		},
+		{
+			name:           "retried only once",
+			accepted:       "Bearer token-3",
+			expectedStatus: http.StatusUnauthorized,
+			expectedCalls:  2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var issued, calls int32
+			tokens := tokenEndpoint(t, 3600, &issued)
+			defer tokens.Close()
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, `{"data":{}}`, string(body), "the body is sent again")
+				if r.Header.Get("Authorization") != test.accepted {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer api.Close()
+
+			client := &http.Client{Transport: &form3_client.OAuth2{
+				Source: &form3_client.ClientCredentials{
+					TokenURL:     tokens.URL,
+					ClientID:     "client",
+					ClientSecret: "s3cr3t",
+					Scopes:       []string{"accounts:read", "accounts:write"},
+				},
+			}}
+			resp, err := client.Post(api.URL, "application/json", strings.NewReader(`{"data":{}}`))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func Test_bearerToken(t *testing.T) {
+	t.Parallel()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	req, _ := http.NewRequest("GET", api.URL, nil)
+	resp, err := (&http.Client{Transport: &form3_client.BearerToken{Token: "s3cr3t"}}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, req.Header.Get("Authorization"), "the request given is left untouched")
+}
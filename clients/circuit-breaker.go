@@ -0,0 +1,128 @@
+package form3_client
+
+import (
+	"context"
+	"form3-interview/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	// StateClosed lets every call through while counting consecutive failures.
+	StateClosed BreakerState = iota
+	// StateOpen fails every call fast until the cool-down has elapsed.
+	StateOpen
+	// StateHalfOpen lets a single probe through; its outcome closes or
+	// re-opens the breaker.
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops Form3Client from calling an account API that keeps
+// failing. Connection errors, 429 and 5xx responses count as failures. A
+// CircuitBreaker is safe for concurrent use and must be shared by pointer.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker.
+	FailureThreshold int
+	// CoolDown is how long the breaker stays open before letting a probe
+	// through.
+	CoolDown time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(failureThreshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		CoolDown:         coolDown,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.CoolDown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a call may go through and, when it may not, how long
+// until the breaker lets a probe through.
+func (b *CircuitBreaker) allow() (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if wait := b.CoolDown - time.Since(b.openedAt); wait > 0 {
+			return wait, false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return 0, true
+	case StateHalfOpen:
+		if b.probing {
+			return b.CoolDown, false
+		}
+		b.probing = true
+		return 0, true
+	}
+	return 0, true
+}
+
+// record updates the breaker with the outcome of a call it allowed.
+func (b *CircuitBreaker) record(ctx context.Context, resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err != nil && ctx.Err() != nil {
+		// A caller giving up says nothing about the health of form3.
+		return
+	}
+	failed := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	if !failed {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func circuitOpen(retryAfter time.Duration) error {
+	appError := models.NewAppError(models.ErrCircuitOpen, "Form3 server unavailable", http.StatusServiceUnavailable)
+	appError.RetryAfter = retryAfter
+	return appError
+}
@@ -0,0 +1,98 @@
+package form3_client_test
+
+import (
+	"context"
+	form3_client "form3-interview/clients"
+	"form3-interview/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_circuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var (
+		attempts int32
+		healthy  int32
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write(createDummyAccount())
+	}))
+	defer testServer.Close()
+
+	breaker := form3_client.NewCircuitBreaker(2, 100*time.Millisecond)
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Breaker:    breaker,
+	}
+	get := func() error {
+		_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+		return err
+	}
+
+	// consecutive failures open the breaker
+	assert.Equal(t, http.StatusInternalServerError, models.StatusCode(get()))
+	assert.Equal(t, form3_client.StateClosed, breaker.State())
+	assert.Equal(t, http.StatusInternalServerError, models.StatusCode(get()))
+	assert.Equal(t, form3_client.StateOpen, breaker.State())
+
+	// while open, calls fail fast without reaching the server
+	err := get()
+	assert.True(t, models.IsCircuitOpen(err))
+	assert.Equal(t, http.StatusServiceUnavailable, models.StatusCode(err))
+	assert.Greater(t, int64(appErrorOf(t, err).RetryAfter), int64(0))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// a failed probe re-opens it
+	time.Sleep(120 * time.Millisecond)
+	assert.Equal(t, form3_client.StateHalfOpen, breaker.State())
+	assert.Equal(t, http.StatusInternalServerError, models.StatusCode(get()))
+	assert.Equal(t, form3_client.StateOpen, breaker.State())
+	assert.True(t, models.IsCircuitOpen(get()))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// a successful probe closes it
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(120 * time.Millisecond)
+	assert.NoError(t, get())
+	assert.Equal(t, form3_client.StateClosed, breaker.State())
+	assert.NoError(t, get())
+	assert.Equal(t, int32(5), atomic.LoadInt32(&attempts))
+}
+
+func Test_circuitBreakerStopsRetries(t *testing.T) {
+	t.Parallel()
+
+	var attempts int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Retry: &form3_client.RetryPolicy{
+			MaxAttempts: 5,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    time.Millisecond,
+		},
+		Breaker: form3_client.NewCircuitBreaker(2, time.Minute),
+	}
+
+	err := client.DeleteAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", 0)
+	assert.True(t, models.IsCircuitOpen(err))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
@@ -1,51 +1,110 @@
 package form3_client
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"form3-interview/logging"
+	"form3-interview/metrics"
 	"form3-interview/models"
+	"form3-interview/tracing"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	pathUrl = "v1/organisation/accounts"
+	pathUrl   = "v1/organisation/accounts"
+	healthUrl = "v1/health"
 )
 
 type Form3ClientIface interface {
-	GetAccount(accountId string) (account models.AccountWrapper, err models.AppError)
-	PostAccount(body io.Reader) (account models.AccountWrapper, err models.AppError)
-	DeleteAccount(accountId string, version string) (err models.AppError)
+	GetAccount(ctx context.Context, accountId string) (account models.AccountWrapper, err error)
+	ListAccounts(ctx context.Context, options ListOptions) (accounts []models.AccountData, links models.Links, err error)
+	CreateAccount(ctx context.Context, accountData models.AccountData) (account models.AccountWrapper, err error)
+	PostAccount(ctx context.Context, body io.Reader) (account models.AccountWrapper, err error)
+	UpdateAccount(ctx context.Context, accountId string, version int64, attributes models.AccountAttributes) (account models.AccountWrapper, err error)
+	DeleteAccount(ctx context.Context, accountId string, version int64) (err error)
+	DeleteCurrentAccount(ctx context.Context, accountId string) (err error)
+	Health(ctx context.Context) (health models.Health, err error)
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// RequestSigner signs a request before it is sent, as signing.Signer does.
+type RequestSigner interface {
+	Sign(req *http.Request) error
+}
+
 type Form3Client struct {
 	HttpClient *http.Client
 	BaseURL    string
+	// Retry is the policy applied to transient failures; nil disables retries.
+	Retry *RetryPolicy
+	// Breaker fails calls fast while form3 is down; nil disables it.
+	Breaker *CircuitBreaker
+	// Metrics records the calls made to form3; nil disables it.
+	Metrics *metrics.Metrics
+	// TracerProvider creates the spans of the calls made to form3; nil creates
+	// none, though the trace context of the caller is still forwarded.
+	TracerProvider trace.TracerProvider
+	// Signer signs every attempt at calling form3; nil sends requests
+	// unsigned.
+	Signer RequestSigner
+	// VersionConflictRetries is how many times DeleteCurrentAccount fetches
+	// the account again after its delete lost a race with another update.
+	VersionConflictRetries int
+}
+
+// ListOptions holds the paging and filtering parameters of a list request.
+// Filter keys are the bare attribute names, e.g. "country" for filter[country].
+type ListOptions struct {
+	PageNumber int
+	PageSize   int
+	Filter     map[string]string
+}
+
+func (o ListOptions) encode() string {
+	query := url.Values{}
+	query.Set("page[number]", strconv.Itoa(o.PageNumber))
+	if o.PageSize > 0 {
+		query.Set("page[size]", strconv.Itoa(o.PageSize))
+	}
+	for key, value := range o.Filter {
+		query.Set("filter["+key+"]", value)
+	}
+	return query.Encode()
 }
 
-func (c Form3Client) GetAccount(accountId string) (account models.AccountWrapper, appError models.AppError) {
+func (c Form3Client) GetAccount(ctx context.Context, accountId string) (account models.AccountWrapper, err error) {
 
 	var (
 		resp *http.Response
 		req  *http.Request
-		err  error
 	)
 	url := c.BaseURL
 	fullUrl := url + pathUrl + "/" + accountId
 
-	if req, err = http.NewRequest("GET", fullUrl, nil); err != nil {
+	if req, err = http.NewRequestWithContext(ctx, "GET", fullUrl, nil); err != nil {
 		return account, models.NewAppError(err, "Malfunctioned http client request", 500)
 	}
 
 	if resp, err = c.Do(req); err != nil {
-		return account, models.NewAppError(err, "Unable to reach form3 server", 500)
+		return account, unreachable(err)
 	}
 	defer resp.Body.Close()
 
-	if appError = validation(resp); appError.Error != nil {
-		return account, models.NewAppError(appError.Error, "Validation error", appError.Code)
+	if err = validation(resp); err != nil {
+		return account, err
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&account)
@@ -55,79 +114,390 @@ func (c Form3Client) GetAccount(accountId string) (account models.AccountWrapper
 	return
 }
 
-func (c Form3Client) PostAccount(body io.Reader) (account models.AccountWrapper, appError models.AppError) {
+func (c Form3Client) ListAccounts(ctx context.Context, options ListOptions) (accounts []models.AccountData, links models.Links, err error) {
+	var page models.AccountListWrapper
+
+	fullUrl := c.BaseURL + pathUrl + "?" + options.encode()
+	if page, err = c.listAccountsPage(ctx, fullUrl); err != nil {
+		return accounts, links, err
+	}
+	return page.Accounts, page.Links, nil
+}
+
+func (c Form3Client) listAccountsPage(ctx context.Context, fullUrl string) (page models.AccountListWrapper, err error) {
+	var (
+		resp *http.Response
+		req  *http.Request
+	)
+
+	if req, err = http.NewRequestWithContext(ctx, "GET", fullUrl, nil); err != nil {
+		return page, models.NewAppError(err, "Malfunctioned http client request", 500)
+	}
+
+	if resp, err = c.Do(req); err != nil {
+		return page, unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	if err = validation(resp); err != nil {
+		return page, err
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		return page, models.NewAppError(err, "Unable to decode the account list response from form3 client", 500)
+	}
+	return
+}
+
+// CreateAccount wraps accountData in the form3 envelope and creates it. Type
+// defaults to "accounts" and a random id is generated when none is set. The
+// account is validated locally first, so invalid data never reaches form3.
+//
+// When ctx carries an idempotency key, a missing id is derived from it with
+// IdempotentAccountID instead, and a 409 for an account that already holds
+// exactly accountData is taken as the outcome of an earlier attempt and the
+// stored account is returned instead of the conflict.
+func (c Form3Client) CreateAccount(ctx context.Context, accountData models.AccountData) (account models.AccountWrapper, err error) {
+	var body []byte
+
+	if key, ok := IdempotencyKeyFromContext(ctx); ok && len(accountData.ID) == 0 {
+		accountData.ID = IdempotentAccountID(key)
+	}
+	accountData.SetDefaults()
+	if err = accountData.Validate(); err != nil {
+		return account, models.NewAppError(err, "Validation error", http.StatusBadRequest)
+	}
+	if body, err = json.Marshal(models.AccountWrapper{Account: accountData}); err != nil {
+		return account, models.NewAppError(err, "Unable to encode the account for form3 client", 500)
+	}
+	account, err = c.PostAccount(ctx, bytes.NewReader(body))
+	if _, idempotent := IdempotencyKeyFromContext(ctx); !idempotent || !models.IsConflict(err) {
+		return account, err
+	}
+	if stored, getErr := c.GetAccount(ctx, accountData.ID); getErr == nil && sameAccount(accountData, stored.Account) {
+		return stored, nil
+	}
+	return account, err
+}
+
+// PostAccount sends body to form3 as it is.
+func (c Form3Client) PostAccount(ctx context.Context, body io.Reader) (account models.AccountWrapper, err error) {
 	var (
 		resp *http.Response
 		req  *http.Request
-		err  error
 	)
 
 	url := c.BaseURL
 	fullUrl := url + pathUrl
 
-	if req, err = http.NewRequest("POST", fullUrl, body); err != nil {
+	if req, err = http.NewRequestWithContext(ctx, "POST", fullUrl, body); err != nil {
 		return account, models.NewAppError(err, "Malfunctioned http client request", 500)
 	}
 
 	if resp, err = c.Do(req); err != nil {
-		return account, models.NewAppError(err, "Unable to reach form3 server", 500)
+		return account, unreachable(err)
 	}
 	defer resp.Body.Close()
 
-	if appError = validation(resp); appError.Error != nil {
-		return account, models.NewAppError(appError.Error, "Validation error", appError.Code)
+	if err = validation(resp); err != nil {
+		return account, err
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&account)
 	if err != nil {
-		return account, models.NewAppError(appError.Error, "Unable to decode the account response from form3 client", 500)
+		return account, models.NewAppError(err, "Unable to decode the account response from form3 client", 500)
 	}
 	return
 }
 
-func (c Form3Client) DeleteAccount(accountId string, version string) (appError models.AppError) {
+// UpdateAccount patches the attributes of version of the account. Only the
+// attributes that are set are sent, so nil pointers and empty values are left
+// untouched on the account. form3 answers 409 when version is not current.
+func (c Form3Client) UpdateAccount(ctx context.Context, accountId string, version int64, attributes models.AccountAttributes) (account models.AccountWrapper, err error) {
+	var (
+		resp *http.Response
+		req  *http.Request
+		body []byte
+	)
+
+	if err = attributes.ValidateUpdate(); err != nil {
+		return account, models.NewAppError(err, "Validation error", http.StatusBadRequest)
+	}
+	patch := models.AccountWrapper{
+		Account: models.AccountData{
+			ID:         accountId,
+			Type:       models.AccountType,
+			Version:    &version,
+			Attributes: &attributes,
+		},
+	}
+	if body, err = json.Marshal(patch); err != nil {
+		return account, models.NewAppError(err, "Unable to encode the account for form3 client", 500)
+	}
+
+	fullUrl := c.BaseURL + pathUrl + "/" + accountId
+	if req, err = http.NewRequestWithContext(ctx, "PATCH", fullUrl, bytes.NewReader(body)); err != nil {
+		return account, models.NewAppError(err, "Malfunctioned http client request", 500)
+	}
+
+	if resp, err = c.Do(req); err != nil {
+		return account, unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	if err = validation(resp); err != nil {
+		return account, err
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&account)
+	if err != nil {
+		return account, models.NewAppError(err, "Unable to decode the account response from form3 client", 500)
+	}
+	return
+}
+
+func (c Form3Client) DeleteAccount(ctx context.Context, accountId string, version int64) (err error) {
 
 	var (
 		req  *http.Request
 		resp *http.Response
-		err  error
 	)
 	url := c.BaseURL
 
-	fullUrl := url + pathUrl + "/" + accountId + "?version=" + version
+	fullUrl := url + pathUrl + "/" + accountId + "?version=" + strconv.FormatInt(version, 10)
 
-	if req, err = http.NewRequest("DELETE", fullUrl, nil); err != nil {
+	if req, err = http.NewRequestWithContext(ctx, "DELETE", fullUrl, nil); err != nil {
 		return models.NewAppError(err, "Malfunctioned http client request", 500)
 	}
 	if resp, err = c.Do(req); err != nil {
-		return models.NewAppError(err, "Unable to reach form3 server", 500)
+		return unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	if err = validation(resp); err != nil {
+		return err
 	}
-	if appError = validation(resp); appError.Error != nil {
-		return models.NewAppError(appError.Error, "Validation error", appError.Code)
+	return
+}
+
+// DeleteCurrentAccount deletes the account whatever its version: it fetches
+// the account to learn its current version and deletes that version. A 409
+// means the account changed in between, in which case it starts over, up to
+// VersionConflictRetries times.
+func (c Form3Client) DeleteCurrentAccount(ctx context.Context, accountId string) (err error) {
+	var (
+		account models.AccountWrapper
+		version int64
+	)
+	for attempt := 0; ; attempt++ {
+		if account, err = c.GetAccount(ctx, accountId); err != nil {
+			return err
+		}
+		version = 0
+		if account.Account.Version != nil {
+			version = *account.Account.Version
+		}
+		err = c.DeleteAccount(ctx, accountId, version)
+		if err == nil || !models.IsConflict(err) || attempt >= c.VersionConflictRetries {
+			return err
+		}
+	}
+}
+
+// Health asks form3 whether the account API is up. The answer is only
+// meaningful when err is nil; callers probing readiness should bound ctx with
+// a short timeout.
+func (c Form3Client) Health(ctx context.Context) (health models.Health, err error) {
+	var (
+		resp *http.Response
+		req  *http.Request
+	)
+
+	if req, err = http.NewRequestWithContext(ctx, "GET", c.BaseURL+healthUrl, nil); err != nil {
+		return health, models.NewAppError(err, "Malfunctioned http client request", 500)
+	}
+
+	if resp, err = c.Do(req); err != nil {
+		return health, unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	if err = validation(resp); err != nil {
+		return health, err
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	if err != nil {
+		return health, models.NewAppError(err, "Unable to decode the health response from form3 client", 500)
 	}
 	return
 }
 
-func validation(resp *http.Response) (appError models.AppError) {
+func validation(resp *http.Response) error {
 
 	status := resp.StatusCode
 	if status == http.StatusOK || status == http.StatusNoContent || status == http.StatusCreated {
-		return appError
+		return nil
 	} else {
 		respBody, _ := ioutil.ReadAll(resp.Body)
-		err := errors.New(string(respBody))
-		return models.NewAppError(err, string(respBody), status)
+		form3Error := models.ParseForm3Error(respBody)
+		message := string(respBody)
+		if form3Error != nil {
+			message = form3Error.ErrorMessage
+		}
+		appError := models.NewAppError(errors.New(message), "Validation error", status)
+		appError.Form3Error = form3Error
+		appError.RequestID = resp.Header.Get("X-Request-Id")
+		return appError
+	}
+}
+
+// unreachable reports a failed round trip to form3. A request whose context
+// deadline expired is reported as a gateway timeout.
+func unreachable(err error) error {
+	var appError *models.AppError
+	if errors.As(err, &appError) {
+		return err
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return models.NewAppError(err, "Timed out waiting for form3 server", 504)
+	}
+	return models.NewAppError(err, "Unable to reach form3 server", 500)
 }
 
+// Do sends req to form3, retrying transient failures as allowed by the
+// client's RetryPolicy. Every attempt goes through the client's
+// CircuitBreaker; while it is open Do fails fast with an AppError wrapping
+// models.ErrCircuitOpen. Every attempt is logged and recorded in the client's
+// Metrics, and the whole call is traced in a span whose context is sent to
+// form3 as traceparent. Requests are signed by the client's Signer when it has
+// one.
 func (c *Form3Client) Do(req *http.Request) (*http.Response, error) {
 	var (
-		resp *http.Response
-		err  error
+		resp    *http.Response
+		err     error
+		attempt int
 	)
+	ctx, span := tracing.Tracer(c.TracerProvider).Start(req.Context(), "form3 "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	defer span.End()
+	if accountId := accountIdOf(req.URL.Path); len(accountId) != 0 {
+		span.SetAttributes(tracing.AccountIDKey.String(accountId))
+	}
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+
 	req.Header.Set("Content-Type", "application/json")
-	if resp, err = c.HttpClient.Do(req); err != nil {
+	if key, ok := IdempotencyKeyFromContext(req.Context()); ok && len(req.Header.Get(IdempotencyKeyHeader)) == 0 {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if requestID, ok := logging.RequestIDFromContext(req.Context()); ok && len(req.Header.Get(logging.RequestIDHeader)) == 0 {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	logger := logging.FromContext(req.Context()).WithFields(logrus.Fields{
+		"upstream_method": req.Method,
+		"upstream_path":   req.URL.Path,
+	})
+	attempts := c.Retry.attempts(req)
+	for attempt = 1; ; attempt++ {
+		// Signed on every attempt, so that retries carry a fresh Date.
+		if c.Signer != nil {
+			if err = c.Signer.Sign(req); err != nil {
+				err = models.NewAppError(err, "Could not sign request to form3", http.StatusInternalServerError)
+				traceUpstream(span, attempt, nil, err)
+				return nil, err
+			}
+		}
+		retryAfter, ok := c.Breaker.allow()
+		c.Metrics.SetCircuitBreakerState(int(c.Breaker.State()))
+		if !ok {
+			c.Metrics.CircuitBreakerRejected()
+			logger.WithField("retry_after", retryAfter.String()).Warn("Form3 call refused, circuit breaker is open")
+			err = circuitOpen(retryAfter)
+			traceUpstream(span, attempt, nil, err)
+			return nil, err
+		}
+		c.Metrics.UpstreamStarted()
+		start := time.Now()
+		resp, err = c.HttpClient.Do(req)
+		c.Breaker.record(req.Context(), resp, err)
+		c.Metrics.SetCircuitBreakerState(int(c.Breaker.State()))
+		c.observeUpstream(logger.WithField("attempt", attempt), req.Method, resp, err, time.Since(start))
+		if attempt >= attempts || !c.Retry.shouldRetry(req.Context(), resp, err) {
+			break
+		}
+		delay, ok := c.Retry.delay(attempt-1, resp)
+		if !ok {
+			logger.WithFields(logrus.Fields{"attempt": attempt, "retry_after": delay.String()}).Info("Not retrying form3 call, Retry-After exceeds the max delay")
+			break
+		}
+		c.Metrics.UpstreamRetried(req.Method)
+		logger.WithFields(logrus.Fields{"attempt": attempt, "delay": delay.String()}).Info("Retrying form3 call")
+		drain(resp)
+		if err = sleep(req.Context(), delay); err != nil {
+			traceUpstream(span, attempt, nil, err)
+			return nil, err
+		}
+		if err = rewind(req); err != nil {
+			traceUpstream(span, attempt, nil, err)
+			return nil, err
+		}
+	}
+	traceUpstream(span, attempt, resp, err)
+	if err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
+
+// traceUpstream records the outcome of a call to form3 on its span.
+func traceUpstream(span trace.Span, attempts int, resp *http.Response, err error) {
+	span.SetAttributes(attribute.Int("form3.attempts", attempts))
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(tracing.ErrorKindKey.String(models.ErrorKind(unreachable(err))))
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
+}
+
+// accountIdOf returns the account id of a path of a single account, or "".
+func accountIdOf(path string) string {
+	index := strings.Index(path, pathUrl+"/")
+	if index < 0 {
+		return ""
+	}
+	accountId := path[index+len(pathUrl)+1:]
+	if strings.Contains(accountId, "/") {
+		return ""
+	}
+	return accountId
+}
+
+// observeUpstream logs and measures the outcome of one attempt at calling
+// form3. It is logged at debug level when it succeeded, and as a warning when
+// it is a failure worth retrying.
+func (c *Form3Client) observeUpstream(logger *logrus.Entry, method string, resp *http.Response, err error, latency time.Duration) {
+	logger = logger.WithField("latency_ms", latency.Milliseconds())
+	if err != nil {
+		errorKind := models.ErrorKind(unreachable(err))
+		c.Metrics.UpstreamFinished(method, 0, errorKind, latency)
+		logger.WithError(err).WithField("error_kind", errorKind).Warn("Form3 call failed")
+		return
+	}
+	c.Metrics.UpstreamFinished(method, resp.StatusCode, models.StatusKind(resp.StatusCode), latency)
+	logger = logger.WithField("upstream_status", resp.StatusCode)
+	if upstreamID := resp.Header.Get("X-Request-Id"); len(upstreamID) != 0 {
+		logger = logger.WithField("upstream_request_id", upstreamID)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		logger.Warn("Form3 call failed")
+		return
+	}
+	logger.Debug("Form3 call completed")
+}
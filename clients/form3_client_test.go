@@ -2,14 +2,35 @@ package form3_client_test
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
 	form3_client "form3-interview/clients"
+	"form3-interview/logging"
+	"form3-interview/metrics"
 	"form3-interview/models"
+	"form3-interview/signing"
+	"form3-interview/tracing"
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_form3ClientGet(t *testing.T) {
@@ -95,7 +116,7 @@ func Test_form3ClientGet(t *testing.T) {
 				BaseURL:    testServer.URL + test.separator,
 			}
 
-			account, err := client.GetAccount(test.pathParam)
+			account, err := client.GetAccount(context.Background(), test.pathParam)
 			if test.err == nil {
 				var expectedAccount models.AccountWrapper
 				err := json.Unmarshal(createDummyAccount(), &expectedAccount)
@@ -105,12 +126,34 @@ func Test_form3ClientGet(t *testing.T) {
 				assert.Equal(t, account, expectedAccount)
 			} else {
 				//v := strings.Split(errors.Unwrap(err).Error(), ":")
-				assert.Equal(t, test.err.Error(), err.Message)
+				assert.Equal(t, test.err.Error(), appErrorOf(t, err).Message)
 			}
 		})
 	}
 }
 
+func Test_form3ClientGetKeepsUnknownEnumValues(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(200)
+		res.Write([]byte(strings.Replace(string(createDummyAccount()), `"bank_id_code":"GBDSC"`, `"bank_id_code":"SESBA"`, 1)))
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+	}
+
+	account, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BankIDCode("SESBA"), account.Account.Attributes.BankIDCode)
+	body, err := json.Marshal(account)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"bank_id_code":"SESBA"`)
+}
+
 func Test_form3ClientPost(t *testing.T) {
 	t.Parallel()
 
@@ -185,7 +228,7 @@ func Test_form3ClientPost(t *testing.T) {
 			}
 
 			body := bytes.NewReader(test.givenPayload)
-			account, err := client.PostAccount(body)
+			account, err := client.PostAccount(context.Background(), body)
 			if test.err == nil {
 				var expectedAccount models.AccountWrapper
 				err := json.Unmarshal(createDummyAccount(), &expectedAccount)
@@ -195,7 +238,404 @@ func Test_form3ClientPost(t *testing.T) {
 				assert.Equal(t, account, expectedAccount)
 			} else {
 				//v := strings.Split(errors.Unwrap(err).Error(), ":")
-				assert.Equal(t, test.err.Error(), err.Message)
+				assert.Equal(t, test.err.Error(), appErrorOf(t, err).Message)
+			}
+		})
+	}
+}
+
+func Test_form3ClientCreate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		givenAccount models.AccountData
+		expectedType models.ResourceType
+		expectedId   string
+		err          string
+	}{
+		{
+			name:         "type and id defaulted",
+			givenAccount: models.AccountData{OrganisationID: "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c", Attributes: dummyAttributes()},
+			expectedType: "accounts",
+		},
+		{
+			name: "type and id kept",
+			givenAccount: models.AccountData{
+				ID:             "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+				OrganisationID: "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c",
+				Type:           "accounts",
+				Attributes:     dummyAttributes(),
+			},
+			expectedType: "accounts",
+			expectedId:   "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+		},
+		{
+			name:         "invalid account never reaches the server",
+			givenAccount: models.AccountData{OrganisationID: "1234"},
+			err:          "organisation_id must be a valid uuid; attributes is required",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				assert.Empty(t, test.err, "server must not be called")
+				assert.Equal(t, "POST", req.Method)
+				var sent models.AccountWrapper
+				if err := json.NewDecoder(req.Body).Decode(&sent); err != nil {
+					res.WriteHeader(400)
+					return
+				}
+				res.WriteHeader(201)
+				json.NewEncoder(res).Encode(sent)
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+
+			account, err := client.CreateAccount(context.Background(), test.givenAccount)
+			if len(test.err) != 0 {
+				var validationErrors models.ValidationErrors
+				assert.True(t, errors.As(err, &validationErrors))
+				assert.Equal(t, test.err, validationErrors.Error())
+				assert.True(t, models.IsValidation(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedType, account.Account.Type)
+			assert.Equal(t, test.givenAccount.OrganisationID, account.Account.OrganisationID)
+			if len(test.expectedId) == 0 {
+				assert.NotNil(t, uuid.Parse(account.Account.ID))
+			} else {
+				assert.Equal(t, test.expectedId, account.Account.ID)
+			}
+		})
+	}
+}
+
+func Test_form3ClientCreateKeepsAllFields(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		res.WriteHeader(201)
+		res.Write(body)
+	}))
+	defer testServer.Close()
+
+	given := dummyAccountData()
+	given.Relationships = &models.AccountRelationships{
+		MasterAccount: &models.RelationshipLinks{Data: []models.ResourceIdentifier{{ID: "a52d13a4-f435-4c00-cfad-f5e7ac5972df", Type: "accounts"}}},
+	}
+	given.Attributes.ReferenceMask = "############"
+	given.Attributes.UserDefinedData = []models.UserDefinedData{{Key: "key", Value: "value"}}
+	given.Attributes.PrivateIdentification = &models.PrivateIdentification{Identification: "13YH458762", BirthCountry: "GB"}
+	given.Attributes.Extra = map[string]json.RawMessage{"name_matching_status": json.RawMessage(`"supported"`)}
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+	}
+	account, err := client.CreateAccount(context.Background(), given)
+	assert.NoError(t, err)
+	assert.Equal(t, given, account.Account)
+}
+
+func Test_form3ClientCreateIdempotent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		key           string
+		storedAccount func() models.AccountData
+		expectedGets  int32
+		err           bool
+	}{
+		{
+			name: "conflict with a matching stored account is a success",
+			key:  "4ff753ac",
+			storedAccount: func() models.AccountData {
+				account := dummyAccountData()
+				version := int64(0)
+				account.Version = &version
+				return account
+			},
+			expectedGets: 1,
+		},
+		{
+			name: "conflict with a different stored account",
+			key:  "4ff753ac",
+			storedAccount: func() models.AccountData {
+				account := dummyAccountData()
+				account.Attributes.Name = []string{"Someone Else"}
+				return account
+			},
+			expectedGets: 1,
+			err:          true,
+		},
+		{
+			name:          "conflict without an idempotency key",
+			storedAccount: dummyAccountData,
+			expectedGets:  0,
+			err:           true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var gets int32
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				if req.Method == http.MethodGet {
+					atomic.AddInt32(&gets, 1)
+					json.NewEncoder(res).Encode(models.AccountWrapper{Account: test.storedAccount()})
+					return
+				}
+				assert.Equal(t, test.key, req.Header.Get("Idempotency-Key"))
+				res.WriteHeader(http.StatusConflict)
+				res.Write([]byte("{\"error_message\":\"Account cannot be created as it violates a duplicate constraint\"}"))
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+
+			ctx := context.Background()
+			if len(test.key) != 0 {
+				ctx = form3_client.WithIdempotencyKey(ctx, test.key)
+			}
+			account, err := client.CreateAccount(ctx, dummyAccountData())
+			if test.err {
+				assert.True(t, models.IsConflict(err))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, dummyAccountData().ID, account.Account.ID)
+			}
+			assert.Equal(t, test.expectedGets, atomic.LoadInt32(&gets))
+		})
+	}
+}
+
+func Test_form3ClientCreateDerivesIdFromIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		accounts = map[string]models.AccountData{}
+		posted   []string
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Method == http.MethodGet {
+			id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			json.NewEncoder(res).Encode(models.AccountWrapper{Account: accounts[id]})
+			return
+		}
+		var sent models.AccountWrapper
+		json.NewDecoder(req.Body).Decode(&sent)
+		posted = append(posted, sent.Account.ID)
+		if _, ok := accounts[sent.Account.ID]; ok {
+			res.WriteHeader(http.StatusConflict)
+			res.Write([]byte("{\"error_message\":\"Account cannot be created as it violates a duplicate constraint\"}"))
+			return
+		}
+		accounts[sent.Account.ID] = sent.Account
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(sent)
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+	}
+	accountData := dummyAccountData()
+	accountData.ID = ""
+
+	ctx := form3_client.WithIdempotencyKey(context.Background(), "k1")
+	first, err := client.CreateAccount(ctx, accountData)
+	assert.NoError(t, err)
+	second, err := client.CreateAccount(ctx, accountData)
+	assert.NoError(t, err, "the retry is recognised as the first attempt")
+
+	assert.Equal(t, form3_client.IdempotentAccountID("k1"), first.Account.ID)
+	assert.Equal(t, first.Account.ID, second.Account.ID)
+	assert.Equal(t, []string{first.Account.ID, first.Account.ID}, posted)
+	assert.Len(t, accounts, 1)
+}
+
+func Test_form3ClientDeleteCurrent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		conflicts        int32
+		retries          int
+		getStatus        int
+		expectedStatus   int
+		expectedVersions []string
+	}{
+		{
+			name:             "deletes the current version",
+			expectedVersions: []string{"3"},
+		},
+		{
+			name:             "retries on version conflict",
+			conflicts:        2,
+			retries:          3,
+			expectedVersions: []string{"3", "4", "5"},
+		},
+		{
+			name:             "gives up after the retry limit",
+			conflicts:        5,
+			retries:          1,
+			expectedStatus:   http.StatusConflict,
+			expectedVersions: []string{"3", "4"},
+		},
+		{
+			name:           "account does not exist",
+			getStatus:      http.StatusNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu       sync.Mutex
+				version  int64 = 3
+				deletes  int32
+				versions []string
+			)
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch req.Method {
+				case http.MethodGet:
+					if test.getStatus != 0 {
+						res.WriteHeader(test.getStatus)
+						res.Write([]byte("{\"error_message\":\"record does not exist\"}"))
+						return
+					}
+					account := dummyAccountData()
+					current := version
+					account.Version = &current
+					json.NewEncoder(res).Encode(models.AccountWrapper{Account: account})
+				case http.MethodDelete:
+					versions = append(versions, req.URL.Query().Get("version"))
+					if deletes++; deletes <= test.conflicts {
+						// another update slipped in between
+						version++
+						res.WriteHeader(http.StatusConflict)
+						res.Write([]byte("{\"error_message\":\"invalid version\"}"))
+						return
+					}
+					res.WriteHeader(http.StatusNoContent)
+				}
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient:             testServer.Client(),
+				BaseURL:                testServer.URL + "/",
+				VersionConflictRetries: test.retries,
+			}
+
+			err := client.DeleteCurrentAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+			if test.expectedStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.expectedStatus, models.StatusCode(err))
+			}
+			assert.Equal(t, test.expectedVersions, versions)
+		})
+	}
+}
+
+func dummyAccountData() models.AccountData {
+	account := dummyAccount().Account
+	account.Version = nil
+	return account
+}
+
+func dummyAttributes() *models.AccountAttributes {
+	return dummyAccount().Account.Attributes
+}
+
+func Test_form3ClientUpdate(t *testing.T) {
+	t.Parallel()
+
+	joint := true
+	testCases := []struct {
+		name         string
+		attributes   models.AccountAttributes
+		serverStatus int
+		expectedBody string
+		err          string
+	}{
+		{
+			name:         "only set attributes are sent",
+			attributes:   models.AccountAttributes{JointAccount: &joint, Name: []string{"Samantha Holder", "Sam Holder"}},
+			serverStatus: http.StatusOK,
+			expectedBody: "{\"data\":{\"attributes\":{\"joint_account\":true,\"name\":[\"Samantha Holder\",\"Sam Holder\"]},\"id\":\"cb1e2074-1056-4b27-b4e0-ed9f0c46b066\",\"type\":\"accounts\",\"version\":2}}",
+		},
+		{
+			name:         "stale version",
+			attributes:   models.AccountAttributes{JointAccount: &joint},
+			serverStatus: http.StatusConflict,
+			expectedBody: "{\"data\":{\"attributes\":{\"joint_account\":true},\"id\":\"cb1e2074-1056-4b27-b4e0-ed9f0c46b066\",\"type\":\"accounts\",\"version\":2}}",
+			err:          "Validation error",
+		},
+		{
+			name:       "invalid attributes never reach the server",
+			attributes: models.AccountAttributes{Bic: "NWBK"},
+			err:        "Validation error",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				assert.NotZero(t, test.serverStatus, "server must not be called")
+				assert.Equal(t, "PATCH", req.Method)
+				assert.Equal(t, "/v1/organisation/accounts/cb1e2074-1056-4b27-b4e0-ed9f0c46b066", req.URL.Path)
+				body, _ := ioutil.ReadAll(req.Body)
+				assert.JSONEq(t, test.expectedBody, string(body))
+				res.WriteHeader(test.serverStatus)
+				if test.serverStatus == http.StatusOK {
+					res.Write(createDummyAccount())
+				} else {
+					res.Write([]byte("{\"error_message\":\"invalid version\"}"))
+				}
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+
+			account, err := client.UpdateAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", 2, test.attributes)
+			if len(test.err) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", account.Account.ID)
+			} else {
+				assert.Equal(t, test.err, appErrorOf(t, err).Message)
+				assert.Equal(t, test.serverStatus == http.StatusConflict, models.IsConflict(err))
 			}
 		})
 	}
@@ -207,7 +647,7 @@ func Test_form3ClientDelete(t *testing.T) {
 	testCases := []struct {
 		name       string
 		accountId  string
-		version    string
+		version    int64
 		err        error
 		testServer *httptest.Server
 		separator  string
@@ -270,14 +710,456 @@ func Test_form3ClientDelete(t *testing.T) {
 				BaseURL:    testServer.URL + test.separator,
 			}
 
-			err := client.DeleteAccount(test.accountId, test.version)
-			if err.Error != nil {
-				assert.Equal(t, test.err.Error(), err.Message)
+			err := client.DeleteAccount(context.Background(), test.accountId, test.version)
+			if err != nil {
+				assert.Equal(t, test.err.Error(), appErrorOf(t, err).Message)
 			}
 		})
 	}
 }
 
+func Test_form3ClientList(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		options       form3_client.ListOptions
+		expectedQuery string
+		err           error
+		testServer    func(t *testing.T, expectedQuery string) *httptest.Server
+	}{
+		{
+			name:    "Validation error coming from server",
+			options: form3_client.ListOptions{PageSize: -1},
+			err:     errors.New("Validation error"),
+			testServer: func(t *testing.T, expectedQuery string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+					res.WriteHeader(400)
+					res.Write([]byte("{\"error_message\":\"invalid page size\"}"))
+				}))
+			},
+		},
+		{
+			name: "bad data coming from server",
+			err:  errors.New("Unable to decode the account list response from form3 client"),
+			testServer: func(t *testing.T, expectedQuery string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+					res.WriteHeader(200)
+					res.Write([]byte("{data {bad:data}"))
+				}))
+			},
+		},
+		{
+			name: "happy path, paging and filter forwarded",
+			options: form3_client.ListOptions{
+				PageNumber: 2,
+				PageSize:   10,
+				Filter:     map[string]string{"country": "GB"},
+			},
+			expectedQuery: "filter[country]=GB&page[number]=2&page[size]=10",
+			testServer: func(t *testing.T, expectedQuery string) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+					query, _ := url.QueryUnescape(req.URL.RawQuery)
+					assert.Equal(t, expectedQuery, query)
+					assert.Equal(t, "/v1/organisation/accounts", req.URL.Path)
+					res.WriteHeader(200)
+					res.Write(createDummyAccountList())
+				}))
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := test.testServer(t, test.expectedQuery)
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+
+			accounts, links, err := client.ListAccounts(context.Background(), test.options)
+			if test.err == nil {
+				var expectedAccount models.AccountWrapper
+				if err := json.Unmarshal(createDummyAccount(), &expectedAccount); err != nil {
+					t.Fatalf("unable to marshal account")
+				}
+				assert.Equal(t, []models.AccountData{expectedAccount.Account}, accounts)
+				assert.Equal(t, "/v1/organisation/accounts?page%5Bnumber%5D=3&page%5Bsize%5D=10", links.Next)
+			} else {
+				assert.Equal(t, test.err.Error(), appErrorOf(t, err).Message)
+			}
+		})
+	}
+}
+
+func Test_form3ClientErrorKinds(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		status        int
+		body          string
+		expectedError *models.Form3Error
+		isNotFound    bool
+		isConflict    bool
+		isValidation  bool
+		isServerError bool
+	}{
+		{
+			name:          "validation envelope",
+			status:        http.StatusBadRequest,
+			body:          "{\"error_message\":\"id is not a valid uuid\",\"error_code\":\"1d0d2c2b\"}",
+			expectedError: &models.Form3Error{ErrorMessage: "id is not a valid uuid", ErrorCode: "1d0d2c2b"},
+			isValidation:  true,
+		},
+		{
+			name:          "record does not exist",
+			status:        http.StatusNotFound,
+			body:          "{\"error_message\":\"record cb1e2074-1056-4b27-b4e0-ed9f0c46b067 does not exist\"}",
+			expectedError: &models.Form3Error{ErrorMessage: "record cb1e2074-1056-4b27-b4e0-ed9f0c46b067 does not exist"},
+			isNotFound:    true,
+		},
+		{
+			name:          "routing envelope",
+			status:        http.StatusNotFound,
+			body:          "{\"code\":\"PAGE_NOT_FOUND\",\"message\":\"Page not found\"}",
+			expectedError: &models.Form3Error{ErrorMessage: "Page not found", ErrorCode: "PAGE_NOT_FOUND"},
+			isNotFound:    true,
+		},
+		{
+			name:          "duplicate account",
+			status:        http.StatusConflict,
+			body:          "{\"error_message\":\"Account cannot be created as it violates a duplicate constraint\"}",
+			expectedError: &models.Form3Error{ErrorMessage: "Account cannot be created as it violates a duplicate constraint"},
+			isConflict:    true,
+		},
+		{
+			name:          "body is not an envelope",
+			status:        http.StatusBadGateway,
+			body:          "<html>bad gateway</html>",
+			expectedError: nil,
+			isServerError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				res.Header().Set("X-Request-Id", "7b9e1f0c-request")
+				res.WriteHeader(test.status)
+				res.Write([]byte(test.body))
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+
+			_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b067")
+			appError := appErrorOf(t, err)
+			assert.Equal(t, test.status, appError.Code)
+			assert.Equal(t, test.expectedError, appError.Form3Error)
+			assert.Equal(t, "7b9e1f0c-request", appError.RequestID)
+			assert.Equal(t, test.isNotFound, models.IsNotFound(err))
+			assert.Equal(t, test.isConflict, models.IsConflict(err))
+			assert.Equal(t, test.isValidation, models.IsValidation(err))
+			assert.Equal(t, test.isServerError, models.IsServerError(err))
+			assert.Equal(t, test.status, models.StatusCode(errors.Wrap(err, "wrapped")))
+		})
+	}
+}
+
+func Test_form3ClientContext(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-release:
+		}
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+	}
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := client.GetAccount(ctx, "cb1e2074-1056-4b27-b4e0-ed9f0c46b067")
+		assert.Equal(t, http.StatusGatewayTimeout, models.StatusCode(err))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		err := client.DeleteAccount(ctx, "cb1e2074-1056-4b27-b4e0-ed9f0c46b067", 0)
+		assert.Equal(t, "Unable to reach form3 server", appErrorOf(t, err).Message)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func Test_form3ClientForwardsRequestID(t *testing.T) {
+	t.Parallel()
+
+	var forwarded string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Get(logging.RequestIDHeader)
+		res.Write(createDummyAccount())
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+	}
+	ctx := logging.WithRequestID(context.Background(), "4ff753ac-bc01-46c5-ad54-055aaaef5a00")
+	_, err := client.GetAccount(ctx, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+	assert.NoError(t, err)
+	assert.Equal(t, "4ff753ac-bc01-46c5-ad54-055aaaef5a00", forwarded)
+}
+
+func Test_form3ClientMetrics(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		res.Write(createDummyAccount())
+	}))
+	defer testServer.Close()
+
+	registry := prometheus.NewRegistry()
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Retry:      &form3_client.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Breaker:    form3_client.NewCircuitBreaker(1, time.Minute),
+		Metrics:    metrics.New(registry),
+	}
+	_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+	assert.True(t, models.IsCircuitOpen(err))
+
+	expected := `
+# HELP form3_proxy_upstream_requests_total Calls made to form3, by method, status and error kind. Status is empty when form3 could not be reached.
+# TYPE form3_proxy_upstream_requests_total counter
+form3_proxy_upstream_requests_total{error_kind="server_error",method="GET",status="503"} 1
+# HELP form3_proxy_upstream_retries_total Calls to form3 retried after a transient failure, by method.
+# TYPE form3_proxy_upstream_retries_total counter
+form3_proxy_upstream_retries_total{method="GET"} 1
+# HELP form3_proxy_circuit_breaker_rejections_total Calls to form3 refused because the circuit breaker was open.
+# TYPE form3_proxy_circuit_breaker_rejections_total counter
+form3_proxy_circuit_breaker_rejections_total 1
+# HELP form3_proxy_circuit_breaker_state State of the circuit breaker: 0 closed, 1 open, 2 half-open.
+# TYPE form3_proxy_circuit_breaker_state gauge
+form3_proxy_circuit_breaker_state 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"form3_proxy_upstream_requests_total", "form3_proxy_upstream_retries_total",
+		"form3_proxy_circuit_breaker_rejections_total", "form3_proxy_circuit_breaker_state"))
+}
+
+func Test_form3ClientTracing(t *testing.T) {
+	t.Parallel()
+
+	var traceparent string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		res.Write(createDummyAccount())
+	}))
+	defer testServer.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := form3_client.Form3Client{
+		HttpClient:     testServer.Client(),
+		BaseURL:        testServer.URL + "/",
+		TracerProvider: provider,
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, err := client.GetAccount(ctx, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+	parent.End()
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	span := spans[0]
+	assert.Equal(t, "form3 GET", span.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", traceparent)
+	assert.Subset(t, span.Attributes(), []attribute.KeyValue{
+		tracing.AccountIDKey.String("cb1e2074-1056-4b27-b4e0-ed9f0c46b066"),
+		attribute.Int("http.status_code", http.StatusOK),
+		attribute.Int("form3.attempts", 1),
+	})
+}
+
+func Test_form3ClientSignsEveryAttempt(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	verifier := &signing.Verifier{Keys: map[string]crypto.PublicKey{"key-1": &key.PublicKey}}
+	var attempts int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		_, err := verifier.Verify(req)
+		assert.NoError(t, err)
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		res.WriteHeader(http.StatusCreated)
+		res.Write(body)
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Retry:      &form3_client.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Signer:     &signing.Signer{KeyID: "key-1", Key: key},
+	}
+	ctx := form3_client.WithIdempotencyKey(context.Background(), "key")
+	account, err := client.CreateAccount(ctx, dummyAccountData())
+	assert.NoError(t, err)
+	assert.Equal(t, dummyAccountData().ID, account.Account.ID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func Test_form3ClientSigningFailure(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		t.Error("an unsigned request was sent")
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Signer:     failingSigner{},
+	}
+	_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+	assert.EqualError(t, err, "Could not sign request to form3: key unavailable")
+	assert.Equal(t, http.StatusInternalServerError, models.StatusCode(err))
+}
+
+type failingSigner struct{}
+
+func (failingSigner) Sign(req *http.Request) error {
+	return errors.New("key unavailable")
+}
+
+func Test_form3ClientHealth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		status   int
+		body     string
+		expected models.Health
+		err      string
+	}{
+		{
+			name:     "up",
+			status:   http.StatusOK,
+			body:     "{\"status\":\"up\"}",
+			expected: models.Health{Status: "up"},
+		},
+		{
+			name:   "unavailable",
+			status: http.StatusServiceUnavailable,
+			body:   "{\"error_message\":\"database unavailable\"}",
+			err:    "Validation error: database unavailable",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, "/v1/health", req.URL.Path)
+				res.WriteHeader(test.status)
+				res.Write([]byte(test.body))
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+			}
+			health, err := client.Health(context.Background())
+			if len(test.err) != 0 {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, health)
+		})
+	}
+}
+
+func appErrorOf(t *testing.T, err error) *models.AppError {
+	t.Helper()
+	var appError *models.AppError
+	if !errors.As(err, &appError) {
+		t.Fatalf("expected an AppError, got %v", err)
+	}
+	return appError
+}
+
+func createDummyAccountList() []byte {
+	body, _ := json.Marshal(models.AccountListWrapper{
+		Accounts: []models.AccountData{dummyAccount().Account},
+		Links: models.Links{
+			First: "/v1/organisation/accounts?page%5Bnumber%5D=first&page%5Bsize%5D=10",
+			Next:  "/v1/organisation/accounts?page%5Bnumber%5D=3&page%5Bsize%5D=10",
+			Prev:  "/v1/organisation/accounts?page%5Bnumber%5D=1&page%5Bsize%5D=10",
+			Last:  "/v1/organisation/accounts?page%5Bnumber%5D=last&page%5Bsize%5D=10",
+		},
+	})
+	return body
+}
+
 func createDummyAccount() []byte {
-	return []byte("{\n    \"data\": {\n        \"attributes\": {\n            \"account_classification\": \"Personal\",\n            \"account_matching_opt_out\": false,\n            \"alternative_names\": [\n                \"Sam Holder\"\n            ],\n            \"bank_id\": \"400300\",\n            \"bank_id_code\": \"GBDSC\",\n            \"base_currency\": \"GBP\",\n            \"bic\": \"NWBKGB22\",\n            \"country\": \"GB\",\n            \"joint_account\": false,\n            \"name\": [\n                \"Samantha Holder\"\n            ],\n            \"secondary_identification\": \"A1B2C3D4\"\n        },\n        \"id\": \"cb1e2074-1056-4b27-b4e0-ed9f0c46b066\",\n        \"organisation_id\": \"eb0bd6f5-c3f5-44b2-b677-acd23cdde73c\",\n        \"type\": \"accounts\",\n        \"version\": 0\n    }\n}")
+	body, _ := json.Marshal(dummyAccount())
+	return body
+}
+
+func dummyAccount() models.AccountWrapper {
+	account, err := models.GBAccount("eb0bd6f5-c3f5-44b2-b677-acd23cdde73c").
+		WithID("cb1e2074-1056-4b27-b4e0-ed9f0c46b066").
+		WithVersion(0).
+		WithBank(models.BankIDCodeGB, "400300", "NWBKGB22").
+		WithNames("Samantha Holder").
+		WithAlternativeNames("Sam Holder").
+		WithClassification(models.Personal).
+		WithSecondaryIdentification("A1B2C3D4").
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	return account
 }
@@ -0,0 +1,76 @@
+package form3_client
+
+import (
+	"context"
+	"encoding/json"
+	"form3-interview/models"
+	"github.com/pborman/uuid"
+	"reflect"
+)
+
+// IdempotencyKeyHeader marks a request as safe to replay. POST requests are
+// only retried when they carry it.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying key. Requests sent with
+// the returned context carry it in the Idempotency-Key header, which lets Do
+// retry POSTs and CreateAccount recognise its own earlier attempts.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key carried by ctx.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && len(key) != 0
+}
+
+// idempotentAccounts is the namespace of the account ids derived from
+// idempotency keys.
+var idempotentAccounts = uuid.Parse("7759a195-389f-4c09-ac8b-ed365e3892f8")
+
+// IdempotentAccountID returns the id of the account created under the
+// idempotency key. It is the same for every retry of the request, so that
+// form3 answers a retry following a lost response with a conflict on the
+// account the first attempt created, rather than creating another one.
+func IdempotentAccountID(key string) string {
+	return uuid.NewSHA1(idempotentAccounts, []byte(key)).String()
+}
+
+// sameAccount reports whether stored holds everything that was sent. Fields
+// form3 fills in itself, such as the version, are not compared.
+func sameAccount(sent, stored models.AccountData) bool {
+	var sentFields, storedFields map[string]interface{}
+
+	sent.Version, stored.Version = nil, nil
+	if !asFields(sent, &sentFields) || !asFields(stored, &storedFields) {
+		return false
+	}
+	return isSubset(sentFields, storedFields)
+}
+
+func asFields(accountData models.AccountData, fields *map[string]interface{}) bool {
+	body, err := json.Marshal(accountData)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(body, fields) == nil
+}
+
+func isSubset(sent, stored map[string]interface{}) bool {
+	for key, value := range sent {
+		if nested, ok := value.(map[string]interface{}); ok {
+			storedNested, ok := stored[key].(map[string]interface{})
+			if !ok || !isSubset(nested, storedNested) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(value, stored[key]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package form3_client_test
+
+import (
+	"context"
+	"encoding/json"
+	form3_client "form3-interview/clients"
+	"form3-interview/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_form3ClientRetry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		failures         int32
+		failure          func(res http.ResponseWriter)
+		call             func(client form3_client.Form3Client) error
+		expectedAttempts int32
+		expectedStatus   int
+		minElapsed       time.Duration
+	}{
+		{
+			name:     "GET recovers from transient 503",
+			failures: 2,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusServiceUnavailable)
+			},
+			call: func(client form3_client.Form3Client) error {
+				_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+				return err
+			},
+			expectedAttempts: 3,
+		},
+		{
+			name:     "GET gives up after max attempts",
+			failures: 5,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusBadGateway)
+			},
+			call: func(client form3_client.Form3Client) error {
+				_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+				return err
+			},
+			expectedAttempts: 3,
+			expectedStatus:   http.StatusBadGateway,
+		},
+		{
+			name:     "DELETE recovers from a connection reset",
+			failures: 1,
+			failure: func(res http.ResponseWriter) {
+				conn, _, _ := res.(http.Hijacker).Hijack()
+				conn.Close()
+			},
+			call: func(client form3_client.Form3Client) error {
+				return client.DeleteAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", 0)
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name:     "429 honours Retry-After",
+			failures: 1,
+			failure: func(res http.ResponseWriter) {
+				res.Header().Set("Retry-After", "1")
+				res.WriteHeader(http.StatusTooManyRequests)
+			},
+			call: func(client form3_client.Form3Client) error {
+				_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+				return err
+			},
+			expectedAttempts: 2,
+			minElapsed:       time.Second,
+		},
+		{
+			name:     "validation errors are not retried",
+			failures: 5,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusBadRequest)
+			},
+			call: func(client form3_client.Form3Client) error {
+				_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+				return err
+			},
+			expectedAttempts: 1,
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name:     "POST without idempotency key is not retried",
+			failures: 1,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusServiceUnavailable)
+			},
+			call: func(client form3_client.Form3Client) error {
+				_, err := client.PostAccount(context.Background(), strings.NewReader(string(createDummyAccount())))
+				return err
+			},
+			expectedAttempts: 1,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:     "CreateAccount with an idempotency key in the context is retried",
+			failures: 1,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusServiceUnavailable)
+			},
+			call: func(client form3_client.Form3Client) error {
+				var account models.AccountWrapper
+				json.Unmarshal(createDummyAccount(), &account)
+				ctx := form3_client.WithIdempotencyKey(context.Background(), "4ff753ac")
+				_, err := client.CreateAccount(ctx, account.Account)
+				return err
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name:     "POST with idempotency key is retried with its body",
+			failures: 1,
+			failure: func(res http.ResponseWriter) {
+				res.WriteHeader(http.StatusServiceUnavailable)
+			},
+			call: func(client form3_client.Form3Client) error {
+				req, _ := http.NewRequest("POST", client.BaseURL+"v1/organisation/accounts", strings.NewReader(string(createDummyAccount())))
+				req.Header.Set(form3_client.IdempotencyKeyHeader, "4ff753ac")
+				resp, err := client.Do(req)
+				if err == nil && resp.StatusCode != http.StatusOK {
+					return models.NewAppError(nil, resp.Status, resp.StatusCode)
+				}
+				return err
+			},
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var attempts int32
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= test.failures {
+					test.failure(res)
+					return
+				}
+				if req.Method == http.MethodPost {
+					var sent models.AccountWrapper
+					assert.NoError(t, json.NewDecoder(req.Body).Decode(&sent))
+					assert.Equal(t, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", sent.Account.ID)
+				}
+				res.WriteHeader(http.StatusOK)
+				res.Write(createDummyAccount())
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+				Retry: &form3_client.RetryPolicy{
+					MaxAttempts: 3,
+					BaseDelay:   time.Millisecond,
+					MaxDelay:    2 * time.Second,
+				},
+			}
+
+			start := time.Now()
+			err := test.call(client)
+			if test.expectedStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.expectedStatus, models.StatusCode(err))
+			}
+			assert.Equal(t, test.expectedAttempts, atomic.LoadInt32(&attempts))
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(test.minElapsed))
+		})
+	}
+}
+
+func Test_form3ClientRetryStopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	var attempts int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		res.Header().Set("Retry-After", "1")
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := form3_client.Form3Client{
+		HttpClient: testServer.Client(),
+		BaseURL:    testServer.URL + "/",
+		Retry:      form3_client.DefaultRetryPolicy(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetAccount(ctx, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+
+	assert.Equal(t, http.StatusGatewayTimeout, models.StatusCode(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func Test_form3ClientRetryGivesUpOnLongRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	for _, retryAfter := range []string{"3600", "99999999999", "99999999999999999999999"} {
+		retryAfter := retryAfter
+		t.Run(retryAfter, func(t *testing.T) {
+			t.Parallel()
+			var attempts int32
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				res.Header().Set("Retry-After", retryAfter)
+				res.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer testServer.Close()
+
+			client := form3_client.Form3Client{
+				HttpClient: testServer.Client(),
+				BaseURL:    testServer.URL + "/",
+				Retry:      form3_client.DefaultRetryPolicy(),
+			}
+
+			start := time.Now()
+			_, err := client.GetAccount(context.Background(), "cb1e2074-1056-4b27-b4e0-ed9f0c46b066")
+
+			assert.Equal(t, http.StatusServiceUnavailable, models.StatusCode(err))
+			assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+			assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		})
+	}
+}
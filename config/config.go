@@ -0,0 +1,357 @@
+// Package config loads the settings of the service from, in increasing order
+// of precedence, their defaults, an optional YAML file, environment variables
+// and command line flags.
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"form3-interview/logging"
+	"form3-interview/tracing"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server  Server  `yaml:"server"`
+	Inbound Inbound `yaml:"inbound"`
+	Form3   Form3   `yaml:"form3"`
+	Log     Log     `yaml:"log"`
+	Tracing Tracing `yaml:"tracing"`
+}
+
+// Server configures the http.Server the service listens with.
+type Server struct {
+	// Addr is the address to listen on. Port 0 picks a free port.
+	Addr              string        `yaml:"addr"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// WriteTimeout bounds the whole handling of a request, so it should leave
+	// room for the retries of a call to form3.
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes"`
+	// ShutdownTimeout is how long in-flight requests are given to complete
+	// once the service is told to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	TLS             ServerTLS     `yaml:"tls"`
+}
+
+// ServerTLS serves HTTPS when both files are set, plain HTTP otherwise.
+type ServerTLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Inbound configures how the callers of the account routes authenticate, and
+// which organisations each may act on. Callers are not authenticated when no
+// credentials are configured.
+type Inbound struct {
+	APIKeys  []APIKey  `yaml:"api_keys"`
+	HMACKeys []HMACKey `yaml:"hmac_keys"`
+	JWT      JWT       `yaml:"jwt"`
+	// Principals maps the name of each caller, the principal of a key or the
+	// subject of a JWT, to the ids of the organisations it may act on. "*"
+	// allows every organisation.
+	Principals map[string][]string `yaml:"principals"`
+}
+
+type APIKey struct {
+	Key       string `yaml:"key"`
+	Principal string `yaml:"principal"`
+}
+
+type HMACKey struct {
+	ID        string `yaml:"id"`
+	Secret    string `yaml:"secret"`
+	Principal string `yaml:"principal"`
+}
+
+// JWT accepts bearer tokens signed with a key of JWKSFile, whose iss and aud
+// claims match Issuer and Audience when they are set.
+type JWT struct {
+	JWKSFile string `yaml:"jwks_file"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// Enabled reports whether callers must authenticate.
+func (i Inbound) Enabled() bool {
+	return len(i.APIKeys) != 0 || len(i.HMACKeys) != 0 || len(i.JWT.JWKSFile) != 0
+}
+
+// Form3 configures the client of the account API.
+type Form3 struct {
+	BaseURL string `yaml:"base_url"`
+	// Timeout bounds a single attempt of a call, retries aside.
+	Timeout                time.Duration  `yaml:"timeout"`
+	Retry                  Retry          `yaml:"retry"`
+	CircuitBreaker         CircuitBreaker `yaml:"circuit_breaker"`
+	VersionConflictRetries int            `yaml:"version_conflict_retries"`
+	TLS                    ClientTLS      `yaml:"tls"`
+	Auth                   Auth           `yaml:"auth"`
+	Signing                Signing        `yaml:"signing"`
+}
+
+type Retry struct {
+	// MaxAttempts is the total number of attempts; 1 disables retries.
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+}
+
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker; 0 disables it.
+	FailureThreshold int           `yaml:"failure_threshold"`
+	CoolDown         time.Duration `yaml:"cool_down"`
+}
+
+// ClientTLS configures the connections made to form3. CAFile replaces the
+// system roots, CertFile and KeyFile present a client certificate.
+type ClientTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Auth configures how the calls made to form3 are authenticated: with a
+// static Token, or with tokens fetched from TokenURL with the OAuth2 client
+// credentials grant.
+type Auth struct {
+	// Token is sent as a bearer token when set.
+	Token        string `yaml:"token"`
+	TokenURL     string `yaml:"token_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Scopes are requested with each token, separated by spaces.
+	Scopes string `yaml:"scopes"`
+}
+
+// ClientCredentials reports whether tokens are fetched with the client
+// credentials grant.
+func (a Auth) ClientCredentials() bool {
+	return len(a.TokenURL) != 0
+}
+
+// Signing signs the calls made to form3 with the private key in
+// PrivateKeyFile, identified to form3 by KeyID, when both are set.
+type Signing struct {
+	KeyID          string `yaml:"key_id"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter"`
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8081",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Form3: Form3{
+			BaseURL: "http://localhost:8080/",
+			Timeout: 5 * time.Second,
+			Retry: Retry{
+				MaxAttempts: 3,
+				BaseDelay:   100 * time.Millisecond,
+				MaxDelay:    2 * time.Second,
+			},
+			CircuitBreaker: CircuitBreaker{
+				FailureThreshold: 5,
+				CoolDown:         30 * time.Second,
+			},
+			VersionConflictRetries: 3,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		Tracing: Tracing{
+			Exporter: tracing.ExporterNone,
+		},
+	}
+}
+
+// Errors lists every problem found in a configuration.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "Invalid configuration: " + strings.Join(e, "; ")
+}
+
+// Validate returns Errors listing every invalid setting, or nil.
+func (c Config) Validate() error {
+	var problems Errors
+	invalid := func(key, format string, args ...interface{}) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Server.Addr) == 0 {
+		invalid("server.addr", "must be set")
+	}
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"form3.timeout", c.Form3.Timeout},
+		{"form3.retry.base_delay", c.Form3.Retry.BaseDelay},
+		{"form3.retry.max_delay", c.Form3.Retry.MaxDelay},
+		{"form3.circuit_breaker.cool_down", c.Form3.CircuitBreaker.CoolDown},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			invalid(duration.key, "must not be negative, got %s", duration.value)
+		}
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		invalid("server.max_header_bytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+	checkKeyPair(invalid, "server.tls", c.Server.TLS.CertFile, c.Server.TLS.KeyFile)
+
+	for i, key := range c.Inbound.APIKeys {
+		if len(key.Key) == 0 || len(key.Principal) == 0 {
+			invalid(fmt.Sprintf("inbound.api_keys[%d]", i), "key and principal must be set")
+		}
+	}
+	hmacKeyIDs := map[string]bool{}
+	for i, key := range c.Inbound.HMACKeys {
+		if len(key.ID) == 0 || len(key.Secret) == 0 || len(key.Principal) == 0 {
+			invalid(fmt.Sprintf("inbound.hmac_keys[%d]", i), "id, secret and principal must be set")
+		} else if hmacKeyIDs[key.ID] {
+			invalid(fmt.Sprintf("inbound.hmac_keys[%d]", i), "duplicate id %q", key.ID)
+		}
+		hmacKeyIDs[key.ID] = true
+	}
+	if len(c.Inbound.JWT.JWKSFile) == 0 && (len(c.Inbound.JWT.Issuer) != 0 || len(c.Inbound.JWT.Audience) != 0) {
+		invalid("inbound.jwt", "issuer and audience need a jwks_file")
+	}
+	checkFile(invalid, "inbound.jwt.jwks_file", c.Inbound.JWT.JWKSFile)
+	if len(c.Inbound.Principals) != 0 && !c.Inbound.Enabled() {
+		invalid("inbound.principals", "no api_keys, hmac_keys or jwt to authenticate them with")
+	}
+
+	if baseURL, err := url.Parse(c.Form3.BaseURL); err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || len(baseURL.Host) == 0 {
+		invalid("form3.base_url", "must be an absolute http or https URL, got %q", c.Form3.BaseURL)
+	} else if !strings.HasSuffix(baseURL.Path, "/") {
+		invalid("form3.base_url", "must end with a slash, got %q", c.Form3.BaseURL)
+	}
+	if c.Form3.Timeout == 0 {
+		invalid("form3.timeout", "must be set")
+	}
+	if c.Form3.Retry.MaxAttempts < 1 {
+		invalid("form3.retry.max_attempts", "must be at least 1, got %d", c.Form3.Retry.MaxAttempts)
+	}
+	if c.Form3.Retry.MaxDelay < c.Form3.Retry.BaseDelay {
+		invalid("form3.retry.max_delay", "must not be less than form3.retry.base_delay")
+	}
+	if c.Form3.CircuitBreaker.FailureThreshold < 0 {
+		invalid("form3.circuit_breaker.failure_threshold", "must not be negative, got %d", c.Form3.CircuitBreaker.FailureThreshold)
+	}
+	if c.Form3.VersionConflictRetries < 0 {
+		invalid("form3.version_conflict_retries", "must not be negative, got %d", c.Form3.VersionConflictRetries)
+	}
+	checkFile(invalid, "form3.tls.ca_file", c.Form3.TLS.CAFile)
+	checkKeyPair(invalid, "form3.tls", c.Form3.TLS.CertFile, c.Form3.TLS.KeyFile)
+	if auth := c.Form3.Auth; auth.ClientCredentials() || len(auth.ClientID) != 0 || len(auth.ClientSecret) != 0 {
+		if len(auth.TokenURL) == 0 || len(auth.ClientID) == 0 || len(auth.ClientSecret) == 0 {
+			invalid("form3.auth", "token_url, client_id and client_secret must be set together")
+		} else if tokenURL, err := url.Parse(auth.TokenURL); err != nil || (tokenURL.Scheme != "http" && tokenURL.Scheme != "https") || len(tokenURL.Host) == 0 {
+			invalid("form3.auth.token_url", "must be an absolute http or https URL, got %q", auth.TokenURL)
+		}
+		if len(auth.Token) != 0 {
+			invalid("form3.auth", "token cannot be set along with client credentials")
+		}
+	}
+	if (len(c.Form3.Signing.KeyID) == 0) != (len(c.Form3.Signing.PrivateKeyFile) == 0) {
+		invalid("form3.signing", "key_id and private_key_file must be set together")
+	}
+	checkFile(invalid, "form3.signing.private_key_file", c.Form3.Signing.PrivateKeyFile)
+
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level", "unknown level %q", c.Log.Level)
+	}
+	if format := strings.ToLower(c.Log.Format); format != logging.FormatJSON && format != logging.FormatText {
+		invalid("log.format", "expected %q or %q, got %q", logging.FormatJSON, logging.FormatText, c.Log.Format)
+	}
+	if c.Tracing.Exporter != tracing.ExporterNone && c.Tracing.Exporter != tracing.ExporterStdout {
+		invalid("tracing.exporter", "expected %q or %q, got %q", tracing.ExporterNone, tracing.ExporterStdout, c.Tracing.Exporter)
+	}
+
+	if len(problems) != 0 {
+		return problems
+	}
+	return nil
+}
+
+func checkKeyPair(invalid func(key, format string, args ...interface{}), key, certFile, keyFile string) {
+	if (len(certFile) == 0) != (len(keyFile) == 0) {
+		invalid(key, "cert_file and key_file must be set together")
+		return
+	}
+	checkFile(invalid, key+".cert_file", certFile)
+	checkFile(invalid, key+".key_file", keyFile)
+}
+
+func checkFile(invalid func(key, format string, args ...interface{}), key, path string) {
+	if len(path) == 0 {
+		return
+	}
+	if info, err := os.Stat(path); err != nil {
+		invalid(key, "cannot read %q", path)
+	} else if info.IsDir() {
+		invalid(key, "%q is a directory", path)
+	}
+}
+
+// ClientConfig returns the TLS configuration of the connections made to form3,
+// or nil when the defaults apply.
+func (c ClientTLS) ClientConfig() (*tls.Config, error) {
+	if c == (ClientTLS{}) {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if len(c.CAFile) != 0 {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not read form3 CA file")
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("No certificate found in form3 CA file %q", c.CAFile)
+		}
+	}
+	if len(c.CertFile) != 0 {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not load form3 client certificate")
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
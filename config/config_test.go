@@ -0,0 +1,192 @@
+package config_test
+
+import (
+	"form3-interview/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func Test_load(t *testing.T) {
+	testCases := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		args     []string
+		expected func(c *config.Config)
+		err      string
+	}{
+		{
+			name:     "defaults",
+			expected: func(c *config.Config) {},
+		},
+		{
+			name: "file overrides defaults",
+			file: "server:\n  addr: \":9090\"\n  write_timeout: 1m\nform3:\n  retry:\n    max_attempts: 5\n",
+			expected: func(c *config.Config) {
+				c.Server.Addr = ":9090"
+				c.Server.WriteTimeout = time.Minute
+				c.Form3.Retry.MaxAttempts = 5
+			},
+		},
+		{
+			name: "env overrides file",
+			file: "server:\n  addr: \":9090\"\nlog:\n  level: debug\n",
+			env:  map[string]string{"ADDR": ":9191", "FORM3_INSECURE_SKIP_VERIFY": "true"},
+			expected: func(c *config.Config) {
+				c.Server.Addr = ":9191"
+				c.Log.Level = "debug"
+				c.Form3.TLS.InsecureSkipVerify = true
+			},
+		},
+		{
+			name: "flags override env",
+			env:  map[string]string{"ADDR": ":9191", "BASE_URL": "http://accountapi:8080/"},
+			args: []string{"-addr", ":9292", "-form3-timeout=2s", "-form3-insecure-skip-verify"},
+			expected: func(c *config.Config) {
+				c.Server.Addr = ":9292"
+				c.Form3.BaseURL = "http://accountapi:8080/"
+				c.Form3.Timeout = 2 * time.Second
+				c.Form3.TLS.InsecureSkipVerify = true
+			},
+		},
+		{
+			name: "unparsable values",
+			env:  map[string]string{"READ_TIMEOUT": "10", "RETRY_MAX_ATTEMPTS": "three"},
+			err:  `Invalid configuration: server.read_timeout: expected a duration like "10s", got "10"; form3.retry.max_attempts: expected an integer, got "three"`,
+		},
+		{
+			name: "invalid values",
+			args: []string{"-base-url", "localhost:8080", "-retry-max-attempts", "0", "-log-level", "loud", "-tls-cert-file", "cert.pem"},
+			err: `Invalid configuration: server.tls: cert_file and key_file must be set together; ` +
+				`form3.base_url: must be an absolute http or https URL, got "localhost:8080"; ` +
+				`form3.retry.max_attempts: must be at least 1, got 0; ` +
+				`log.level: unknown level "loud"`,
+		},
+		{
+			name: "incomplete client credentials",
+			env:  map[string]string{"FORM3_TOKEN_URL": "https://auth.form3.tech/oauth2/token", "FORM3_CLIENT_ID": "client"},
+			err:  "Invalid configuration: form3.auth: token_url, client_id and client_secret must be set together",
+		},
+		{
+			name: "client credentials and static token",
+			env: map[string]string{
+				"FORM3_TOKEN_URL":     "https://auth.form3.tech/oauth2/token",
+				"FORM3_CLIENT_ID":     "client",
+				"FORM3_CLIENT_SECRET": "s3cr3t",
+				"FORM3_AUTH_TOKEN":    "token",
+			},
+			err: "Invalid configuration: form3.auth: token cannot be set along with client credentials",
+		},
+		{
+			name: "signing key without its file",
+			env:  map[string]string{"SIGNING_KEY_ID": "75a8ba12-fff2-4a52-ad8a-e8b34c5ccec8"},
+			err:  "Invalid configuration: form3.signing: key_id and private_key_file must be set together",
+		},
+		{
+			name: "inbound keys from file",
+			file: "inbound:\n  api_keys:\n  - key: k3y\n    principal: acme\n  hmac_keys:\n  - id: acme-1\n    secret: s3cr3t\n    principal: acme\n" +
+				"  principals:\n    acme: [eb0bd6f5-c3f5-44b2-b677-acd23cdde73c]\n",
+			expected: func(c *config.Config) {
+				c.Inbound.APIKeys = []config.APIKey{{Key: "k3y", Principal: "acme"}}
+				c.Inbound.HMACKeys = []config.HMACKey{{ID: "acme-1", Secret: "s3cr3t", Principal: "acme"}}
+				c.Inbound.Principals = map[string][]string{"acme": {"eb0bd6f5-c3f5-44b2-b677-acd23cdde73c"}}
+			},
+		},
+		{
+			name: "invalid inbound keys",
+			file: "inbound:\n  api_keys:\n  - key: k3y\n  hmac_keys:\n  - id: acme-1\n    secret: a\n    principal: acme\n  - id: acme-1\n    secret: b\n    principal: acme\n",
+			err: `Invalid configuration: inbound.api_keys[0]: key and principal must be set; ` +
+				`inbound.hmac_keys[1]: duplicate id "acme-1"`,
+		},
+		{
+			name: "principals without credentials",
+			file: "inbound:\n  principals:\n    acme: [\"*\"]\n",
+			err:  "Invalid configuration: inbound.principals: no api_keys, hmac_keys or jwt to authenticate them with",
+		},
+		{
+			name: "jwt issuer without keys",
+			env:  map[string]string{"INBOUND_JWT_ISSUER": "https://auth.example.com/"},
+			err:  "Invalid configuration: inbound.jwt: issuer and audience need a jwks_file",
+		},
+		{
+			name: "base url without trailing slash",
+			env:  map[string]string{"BASE_URL": "http://accountapi:8080"},
+			err:  `Invalid configuration: form3.base_url: must end with a slash, got "http://accountapi:8080"`,
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "missing.yaml"},
+			err:  "Could not read configuration file: open missing.yaml: no such file or directory",
+		},
+		{
+			name: "unknown key in file",
+			file: "server:\n  port: 9090\n",
+			err:  "field port not found in type config.Server",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			if len(test.file) != 0 {
+				t.Setenv(config.ConfigFileEnv, writeFile(t, test.file))
+			}
+			loaded, err := config.Load("test", test.args)
+			if len(test.err) != 0 {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			expected := config.Default()
+			test.expected(&expected)
+			assert.Equal(t, expected, loaded)
+		})
+	}
+}
+
+func Test_configRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	c := config.Default()
+	c.Form3.Auth.Token = "s3cr3t"
+	c.Form3.Auth.ClientSecret = "client-s3cr3t"
+	c.Inbound.APIKeys = []config.APIKey{{Key: "api-k3y", Principal: "acme"}}
+	c.Inbound.HMACKeys = []config.HMACKey{{ID: "acme-1", Secret: "hmac-s3cr3t", Principal: "acme"}}
+
+	assert.Equal(t, "[REDACTED]", c.Redacted().Form3.Auth.Token)
+	assert.Equal(t, "s3cr3t", c.Form3.Auth.Token, "the original is left untouched")
+	assert.Equal(t, "[REDACTED]", c.Fields()["form3.auth.token"])
+	assert.Equal(t, "[REDACTED]", c.Fields()["form3.auth.client_secret"])
+	assert.Equal(t, "5s", c.Fields()["form3.timeout"])
+	assert.NotContains(t, c.String(), "s3cr3t")
+	assert.NotContains(t, c.String(), "client-s3cr3t")
+	assert.NotContains(t, c.String(), "api-k3y")
+	assert.NotContains(t, c.String(), "hmac-s3cr3t")
+	assert.Equal(t, "api-k3y", c.Inbound.APIKeys[0].Key, "the original keys are left untouched")
+	assert.Equal(t, "acme", c.Redacted().Inbound.HMACKeys[0].Principal)
+}
+
+func Test_configStringLoadsBack(t *testing.T) {
+	c := config.Default()
+	c.Server.ReadTimeout = 3 * time.Second
+	c.Form3.CircuitBreaker.FailureThreshold = 0
+	t.Setenv(config.ConfigFileEnv, writeFile(t, c.String()))
+
+	loaded, err := config.Load("test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, c, loaded)
+}
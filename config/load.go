@@ -0,0 +1,266 @@
+package config
+
+import (
+	"flag"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFileEnv names the YAML file to load when the -config flag is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+const redacted = "[REDACTED]"
+
+// setting is a single configuration value, with the key naming it in the YAML
+// file and the environment variable and flag overriding it.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	value  interface{} // *string, *int, *bool or *time.Duration
+	secret bool
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.addr", "ADDR", "addr", "address to listen on", &c.Server.Addr, false},
+		{"server.read_timeout", "READ_TIMEOUT", "read-timeout", "maximum duration for reading a request", &c.Server.ReadTimeout, false},
+		{"server.read_header_timeout", "READ_HEADER_TIMEOUT", "read-header-timeout", "maximum duration for reading request headers", &c.Server.ReadHeaderTimeout, false},
+		{"server.write_timeout", "WRITE_TIMEOUT", "write-timeout", "maximum duration before timing out writes of a response", &c.Server.WriteTimeout, false},
+		{"server.idle_timeout", "IDLE_TIMEOUT", "idle-timeout", "maximum duration to wait for the next request on a keep-alive connection", &c.Server.IdleTimeout, false},
+		{"server.max_header_bytes", "MAX_HEADER_BYTES", "max-header-bytes", "maximum size of request headers", &c.Server.MaxHeaderBytes, false},
+		{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "maximum duration to wait for in-flight requests on shutdown", &c.Server.ShutdownTimeout, false},
+		{"server.tls.cert_file", "TLS_CERT_FILE", "tls-cert-file", "certificate to serve HTTPS with", &c.Server.TLS.CertFile, false},
+		{"server.tls.key_file", "TLS_KEY_FILE", "tls-key-file", "private key of the HTTPS certificate", &c.Server.TLS.KeyFile, false},
+		{"inbound.jwt.jwks_file", "INBOUND_JWKS_FILE", "inbound-jwks-file", "JWKS file of the keys signing the JWTs of callers", &c.Inbound.JWT.JWKSFile, false},
+		{"inbound.jwt.issuer", "INBOUND_JWT_ISSUER", "inbound-jwt-issuer", "issuer expected of the JWTs of callers", &c.Inbound.JWT.Issuer, false},
+		{"inbound.jwt.audience", "INBOUND_JWT_AUDIENCE", "inbound-jwt-audience", "audience expected of the JWTs of callers", &c.Inbound.JWT.Audience, false},
+		{"form3.base_url", "BASE_URL", "base-url", "URL of the form3 account API, ending with a slash", &c.Form3.BaseURL, false},
+		{"form3.timeout", "FORM3_TIMEOUT", "form3-timeout", "maximum duration of a single call to form3", &c.Form3.Timeout, false},
+		{"form3.retry.max_attempts", "RETRY_MAX_ATTEMPTS", "retry-max-attempts", "attempts made of a call to form3, the first one included", &c.Form3.Retry.MaxAttempts, false},
+		{"form3.retry.base_delay", "RETRY_BASE_DELAY", "retry-base-delay", "backoff before the first retry", &c.Form3.Retry.BaseDelay, false},
+		{"form3.retry.max_delay", "RETRY_MAX_DELAY", "retry-max-delay", "maximum backoff between retries", &c.Form3.Retry.MaxDelay, false},
+		{"form3.circuit_breaker.failure_threshold", "BREAKER_FAILURE_THRESHOLD", "breaker-failure-threshold", "consecutive failures opening the circuit breaker, 0 to disable it", &c.Form3.CircuitBreaker.FailureThreshold, false},
+		{"form3.circuit_breaker.cool_down", "BREAKER_COOL_DOWN", "breaker-cool-down", "how long the circuit breaker stays open", &c.Form3.CircuitBreaker.CoolDown, false},
+		{"form3.version_conflict_retries", "VERSION_CONFLICT_RETRIES", "version-conflict-retries", "times a delete of the latest version is tried again after a conflict", &c.Form3.VersionConflictRetries, false},
+		{"form3.tls.ca_file", "FORM3_CA_FILE", "form3-ca-file", "CA certificates to verify form3 with", &c.Form3.TLS.CAFile, false},
+		{"form3.tls.cert_file", "FORM3_CERT_FILE", "form3-cert-file", "client certificate presented to form3", &c.Form3.TLS.CertFile, false},
+		{"form3.tls.key_file", "FORM3_KEY_FILE", "form3-key-file", "private key of the client certificate", &c.Form3.TLS.KeyFile, false},
+		{"form3.tls.insecure_skip_verify", "FORM3_INSECURE_SKIP_VERIFY", "form3-insecure-skip-verify", "do not verify the certificate of form3", &c.Form3.TLS.InsecureSkipVerify, false},
+		{"form3.auth.token", "FORM3_AUTH_TOKEN", "form3-auth-token", "bearer token sent to form3", &c.Form3.Auth.Token, true},
+		{"form3.auth.token_url", "FORM3_TOKEN_URL", "form3-token-url", "OAuth2 token endpoint issuing tokens to call form3 with", &c.Form3.Auth.TokenURL, false},
+		{"form3.auth.client_id", "FORM3_CLIENT_ID", "form3-client-id", "OAuth2 client id", &c.Form3.Auth.ClientID, false},
+		{"form3.auth.client_secret", "FORM3_CLIENT_SECRET", "form3-client-secret", "OAuth2 client secret", &c.Form3.Auth.ClientSecret, true},
+		{"form3.auth.scopes", "FORM3_SCOPES", "form3-scopes", "OAuth2 scopes, separated by spaces", &c.Form3.Auth.Scopes, false},
+		{"form3.signing.key_id", "SIGNING_KEY_ID", "signing-key-id", "id of the key signing the calls made to form3", &c.Form3.Signing.KeyID, false},
+		{"form3.signing.private_key_file", "SIGNING_PRIVATE_KEY_FILE", "signing-private-key-file", "PEM private key, RSA or ECDSA, signing the calls made to form3", &c.Form3.Signing.PrivateKeyFile, false},
+		{"log.level", "LOG_LEVEL", "log-level", "debug, info, warn or error", &c.Log.Level, false},
+		{"log.format", "LOG_FORMAT", "log-format", "json or text", &c.Log.Format, false},
+		{"tracing.exporter", "TRACE_EXPORTER", "trace-exporter", "none or stdout", &c.Tracing.Exporter, false},
+	}
+}
+
+// Load returns the configuration of the service named name, as given by args,
+// the command line arguments without the program name, the environment and the
+// YAML file named by the -config flag or CONFIG_FILE. It fails when any of
+// them cannot be parsed or the result is invalid, listing every problem found.
+func Load(name string, args []string) (Config, error) {
+	config := Default()
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv(ConfigFileEnv), "YAML configuration `file`")
+	settings := config.settings()
+	overrides := make([]*override, len(settings))
+	for i, setting := range settings {
+		overrides[i] = &override{isBool: isBool(setting.value)}
+		flags.Var(overrides[i], setting.flag, setting.usage+" ("+setting.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+
+	if len(*configFile) != 0 {
+		if err := loadFile(&config, *configFile); err != nil {
+			return config, err
+		}
+	}
+	var problems Errors
+	for i, setting := range settings {
+		raw, ok := os.LookupEnv(setting.env)
+		if overrides[i].set {
+			raw, ok = overrides[i].raw, true
+		}
+		if !ok {
+			continue
+		}
+		if err := parse(setting.value, raw); err != nil {
+			problems = append(problems, setting.key+": "+err.Error())
+		}
+	}
+	if len(problems) != 0 {
+		return config, problems
+	}
+	return config, config.Validate()
+}
+
+func loadFile(config *Config, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "Could not read configuration file")
+	}
+	if err = yaml.UnmarshalStrict(content, config); err != nil {
+		return errors.Wrapf(err, "Could not parse configuration file %q", path)
+	}
+	return nil
+}
+
+// override records the value of a flag, applied after the environment.
+type override struct {
+	raw    string
+	set    bool
+	isBool bool
+}
+
+func (o *override) String() string {
+	if o == nil {
+		return ""
+	}
+	return o.raw
+}
+
+func (o *override) Set(raw string) error {
+	o.raw, o.set = raw, true
+	return nil
+}
+
+func (o *override) IsBoolFlag() bool {
+	return o.isBool
+}
+
+func isBool(value interface{}) bool {
+	_, ok := value.(*bool)
+	return ok
+}
+
+func parse(value interface{}, raw string) error {
+	switch value := value.(type) {
+	case *string:
+		*value = raw
+	case *int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.Errorf("expected an integer, got %q", raw)
+		}
+		*value = parsed
+	case *bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.Errorf("expected true or false, got %q", raw)
+		}
+		*value = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.Errorf("expected a duration like \"10s\", got %q", raw)
+		}
+		*value = parsed
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with its secrets masked, fit
+// for logging.
+func (c Config) Redacted() Config {
+	for _, setting := range c.settings() {
+		if value, ok := setting.value.(*string); ok && setting.secret && len(*value) != 0 {
+			*value = redacted
+		}
+	}
+	c.Inbound.APIKeys = append([]APIKey(nil), c.Inbound.APIKeys...)
+	for i := range c.Inbound.APIKeys {
+		c.Inbound.APIKeys[i].Key = redacted
+	}
+	c.Inbound.HMACKeys = append([]HMACKey(nil), c.Inbound.HMACKeys...)
+	for i := range c.Inbound.HMACKeys {
+		c.Inbound.HMACKeys[i].Secret = redacted
+	}
+	return c
+}
+
+// Fields returns the settings of the redacted configuration keyed as in the
+// YAML file, to log the effective configuration with.
+func (c Config) Fields() map[string]interface{} {
+	redactedConfig := c.Redacted()
+	fields := map[string]interface{}{}
+	for _, setting := range redactedConfig.settings() {
+		switch value := setting.value.(type) {
+		case *string:
+			fields[setting.key] = *value
+		case *int:
+			fields[setting.key] = *value
+		case *bool:
+			fields[setting.key] = *value
+		case *time.Duration:
+			fields[setting.key] = value.String()
+		}
+	}
+	for key, value := range redactedConfig.lists() {
+		fields[key] = value
+	}
+	return fields
+}
+
+// lists returns the settings that only the YAML file sets, keyed as in it.
+func (c Config) lists() map[string]interface{} {
+	lists := map[string]interface{}{}
+	if len(c.Inbound.APIKeys) != 0 {
+		lists["inbound.api_keys"] = c.Inbound.APIKeys
+	}
+	if len(c.Inbound.HMACKeys) != 0 {
+		lists["inbound.hmac_keys"] = c.Inbound.HMACKeys
+	}
+	if len(c.Inbound.Principals) != 0 {
+		lists["inbound.principals"] = c.Inbound.Principals
+	}
+	return lists
+}
+
+// String dumps the redacted configuration as YAML, durations written like
+// "10s" rather than in nanoseconds.
+func (c Config) String() string {
+	fields := c.Fields()
+	root := yaml.MapSlice{}
+	for _, setting := range c.settings() {
+		root = insert(root, strings.Split(setting.key, "."), fields[setting.key])
+	}
+	for _, key := range []string{"inbound.api_keys", "inbound.hmac_keys", "inbound.principals"} {
+		if value, ok := fields[key]; ok {
+			root = insert(root, strings.Split(key, "."), value)
+		}
+	}
+	content, err := yaml.Marshal(root)
+	if err != nil {
+		return err.Error()
+	}
+	return string(content)
+}
+
+// insert sets the value at path in the nested map, keeping keys in the order
+// they are first inserted.
+func insert(node yaml.MapSlice, path []string, value interface{}) yaml.MapSlice {
+	if len(path) == 1 {
+		return append(node, yaml.MapItem{Key: path[0], Value: value})
+	}
+	for i, item := range node {
+		if item.Key == path[0] {
+			node[i].Value = insert(item.Value.(yaml.MapSlice), path[1:], value)
+			return node
+		}
+	}
+	return append(node, yaml.MapItem{Key: path[0], Value: insert(yaml.MapSlice{}, path[1:], value)})
+}
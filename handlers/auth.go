@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"form3-interview/auth"
+	form3_client "form3-interview/clients"
+	"form3-interview/logging"
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+const organisationFilter = "organisation_id"
+
+// Authentication lets through the requests on which authenticator finds valid
+// credentials, handing their principal, allowed the organisations principals
+// maps it to, down through the request context. Other requests are rejected
+// with 401.
+func Authentication(authenticator auth.Authenticator, principals auth.Principals) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, err := authenticator.Authenticate(r)
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).Warn("Request not authenticated")
+				http.Error(w, errors.Wrap(errors.New("authentication"), "Missing or invalid credentials").Error(), http.StatusUnauthorized)
+				return
+			}
+			ctx := auth.WithPrincipal(r.Context(), principals.Principal(name))
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).WithField("principal", name))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authorizeAccount checks, when the caller may only act on some organisations,
+// that the account belongs to one of them, fetching it from form3. Otherwise
+// it writes the error response and returns false.
+func authorizeAccount(w http.ResponseWriter, r *http.Request, form3Client form3_client.Form3ClientIface, accountId string) bool {
+	if !auth.Restricted(r.Context()) {
+		return true
+	}
+	account, err := form3Client.GetAccount(r.Context(), accountId)
+	if err != nil {
+		writeError(w, r, err)
+		return false
+	}
+	if !auth.Allowed(r.Context(), account.Account.OrganisationID) {
+		writeError(w, r, accountNotFound(accountId))
+		return false
+	}
+	return true
+}
+
+// authorizeList restricts a listing to the organisations of the caller. A
+// caller allowed a single organisation gets its accounts without asking, one
+// allowed several must filter on one of them.
+func authorizeList(r *http.Request, options *form3_client.ListOptions) error {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok || principal.Unrestricted() {
+		return nil
+	}
+	organisationID, filtered := options.Filter[organisationFilter]
+	if filtered {
+		if !principal.Allows(organisationID) {
+			return organisationForbidden(organisationID)
+		}
+		return nil
+	}
+	switch len(principal.Organisations) {
+	case 0:
+		return organisationForbidden("")
+	case 1:
+		if options.Filter == nil {
+			options.Filter = map[string]string{}
+		}
+		options.Filter[organisationFilter] = principal.Organisations[0]
+		return nil
+	}
+	return models.NewAppError(errors.New("Missing 'filter[organisation_id]' param"), "Validation error", http.StatusBadRequest)
+}
+
+// allowedAccounts drops from a listing the accounts of organisations the
+// caller may not act on, should form3 not have applied the organisation
+// filter authorizeList set.
+func allowedAccounts(r *http.Request, accounts []models.AccountData) []models.AccountData {
+	if !auth.Restricted(r.Context()) {
+		return accounts
+	}
+	allowed := make([]models.AccountData, 0, len(accounts))
+	for _, account := range accounts {
+		if auth.Allowed(r.Context(), account.OrganisationID) {
+			allowed = append(allowed, account)
+		}
+	}
+	if dropped := len(accounts) - len(allowed); dropped != 0 {
+		logging.FromContext(r.Context()).WithField("dropped", dropped).Warn("Dropped accounts of other organisations from listing")
+	}
+	return allowed
+}
+
+// accountNotFound is reported for the accounts of organisations the caller may
+// not act on, the way form3 reports missing accounts, so as not to reveal that
+// they exist.
+func accountNotFound(accountId string) error {
+	return models.NewAppError(errors.Errorf("record %s does not exist", accountId), "Validation error", http.StatusNotFound)
+}
+
+func organisationForbidden(organisationID string) error {
+	return models.NewAppError(errors.Errorf("not allowed to act on organisation '%s'", organisationID), "Forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,273 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"form3-interview/auth"
+	form3_client "form3-interview/clients"
+	"form3-interview/handlers"
+	mock_form3_client "form3-interview/mocks"
+	"form3-interview/models"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ownOrganisation   = "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c"
+	otherOrganisation = "0fba3c22-9a84-4c4e-8b4e-0a1c1b3e6f27"
+)
+
+func Test_authentication(t *testing.T) {
+	t.Parallel()
+
+	authenticator := auth.NewAPIKeys(map[string]string{"k3y": "acme"})
+	principals := auth.Principals{"acme": {ownOrganisation}}
+	var principal auth.Principal
+	handler := handlers.Authentication(authenticator, principals)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.FromContext(r.Context())
+	}))
+
+	testCases := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "invalid credentials", key: "guess", status: http.StatusUnauthorized},
+		{name: "valid credentials", key: "k3y", status: http.StatusOK},
+	}
+	for _, test := range testCases {
+		req := httptest.NewRequest("GET", "/form3Client/accounts", nil)
+		if len(test.key) != 0 {
+			req.Header.Set(auth.APIKeyHeader, test.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, test.status, rr.Code, test.name)
+	}
+	assert.Equal(t, auth.Principal{Name: "acme", Organisations: []string{ownOrganisation}}, principal)
+}
+
+func accountOf(organisationID string) models.AccountWrapper {
+	account := mockedAccount()
+	account.Account.OrganisationID = organisationID
+	return account
+}
+
+func Test_handlersRestrictOrganisations(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		organisations []string
+		handler       func(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request)
+		method        string
+		target        string
+		body          string
+		mockShop      func(mock *mock_form3_client.MockForm3ClientIface)
+		status        int
+	}{
+		{
+			name:          "get an account of the organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.GetAccount,
+			method:        "GET",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(accountOf(ownOrganisation), nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:          "get an account of another organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.GetAccount,
+			method:        "GET",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(accountOf(otherOrganisation), nil)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:          "create an account in the organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.CreateAccount,
+			method:        "POST",
+			body:          mockedAccountJson(),
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(mockedAccount(), nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:          "create an account in another organisation",
+			organisations: []string{otherOrganisation},
+			handler:       handlers.CreateAccount,
+			method:        "POST",
+			body:          mockedAccountJson(),
+			mockShop:      func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:        http.StatusForbidden,
+		},
+		{
+			name:          "delete an account of the organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.DeleteAccount,
+			method:        "DELETE",
+			target:        "?version=0",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				gomock.InOrder(
+					mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(accountOf(ownOrganisation), nil),
+					mock.EXPECT().DeleteAccount(gomock.Any(), "1234", int64(0)).Return(nil),
+				)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:          "delete the latest version of an account of another organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.DeleteAccount,
+			method:        "DELETE",
+			target:        "?version=latest",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(accountOf(otherOrganisation), nil)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:          "delete as a principal allowed every organisation",
+			organisations: []string{auth.AnyOrganisation},
+			handler:       handlers.DeleteAccount,
+			method:        "DELETE",
+			target:        "?version=0",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().DeleteAccount(gomock.Any(), "1234", int64(0)).Return(nil)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:          "update an account of another organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.UpdateAccount,
+			method:        "PATCH",
+			body:          "{\"data\":{\"version\":0,\"attributes\":{\"joint_account\":true}}}",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(accountOf(otherOrganisation), nil)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:          "update an account missing in form3",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.UpdateAccount,
+			method:        "PATCH",
+			body:          "{\"data\":{\"version\":0,\"attributes\":{\"joint_account\":true}}}",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(models.AccountWrapper{}, models.NewAppError(errors.New("record 1234 does not exist"), "Validation error", 404))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:          "list the accounts of the only organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.ListAccounts,
+			method:        "GET",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().ListAccounts(gomock.Any(), form3_client.ListOptions{Filter: map[string]string{"organisation_id": ownOrganisation}}).Return(nil, models.Links{}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:          "list the accounts of one of several organisations",
+			organisations: []string{ownOrganisation, otherOrganisation},
+			handler:       handlers.ListAccounts,
+			method:        "GET",
+			target:        "?filter[organisation_id]=" + otherOrganisation,
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().ListAccounts(gomock.Any(), form3_client.ListOptions{Filter: map[string]string{"organisation_id": otherOrganisation}}).Return(nil, models.Links{}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:          "list the accounts of several organisations",
+			organisations: []string{ownOrganisation, otherOrganisation},
+			handler:       handlers.ListAccounts,
+			method:        "GET",
+			mockShop:      func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:        http.StatusBadRequest,
+		},
+		{
+			name:          "list the accounts of another organisation",
+			organisations: []string{ownOrganisation},
+			handler:       handlers.ListAccounts,
+			method:        "GET",
+			target:        "?filter[organisation_id]=" + otherOrganisation,
+			mockShop:      func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:        http.StatusForbidden,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			test.mockShop(mockClient)
+
+			req := httptest.NewRequest(test.method, "/form3Client/accounts/1234"+test.target, strings.NewReader(test.body))
+			req = mux.SetURLVars(req, map[string]string{"accountId": "1234"})
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "acme", Organisations: test.organisations}))
+			rr := httptest.NewRecorder()
+			test.handler(mockClient)(rr, req)
+
+			assert.Equal(t, test.status, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func Test_idempotentKeysScopedToPrincipal(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	handler := handlers.Idempotent(handlers.NewMemoryIdempotencyStore(time.Hour), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+	for _, name := range []string{"acme", "globex", "acme"} {
+		req := httptest.NewRequest("POST", "/form3Client/accounts", strings.NewReader(mockedAccountJson()))
+		req.Header.Set(form3_client.IdempotencyKeyHeader, "key")
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: name}))
+		handler(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 2, calls, "only the request of the same principal is replayed")
+}
+
+func Test_listAccountsDropsOtherOrganisations(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	foreign := accountOf(otherOrganisation).Account
+	foreign.ID = "7f1b6c3e-2a4d-4e8b-9c51-0d2e3f4a5b6c"
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	mockClient.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).
+		Return([]models.AccountData{accountOf(ownOrganisation).Account, foreign}, models.Links{}, nil)
+
+	req := httptest.NewRequest("GET", "/form3Client/accounts", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "acme", Organisations: []string{ownOrganisation}}))
+	rr := httptest.NewRecorder()
+	handlers.ListAccounts(mockClient)(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var listed models.AccountListWrapper
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &listed))
+	if assert.Len(t, listed.Accounts, 1) {
+		assert.Equal(t, ownOrganisation, listed.Accounts[0].OrganisationID)
+	}
+}
@@ -2,19 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"form3-interview/auth"
 	form3_client "form3-interview/clients"
+	"form3-interview/logging"
 	"form3-interview/models"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+const latestVersion = "latest"
+
 func GetAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
-			account  models.AccountWrapper
-			err      error
-			appError models.AppError
+			account models.AccountWrapper
+			err     error
 		)
 		w.Header().Set("Content-Type", "application/json")
 		pathParams := mux.Vars(r)
@@ -23,10 +30,15 @@ func GetAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseW
 			http.Error(w, errors.Wrap(errors.New("validation"), "Missing 'accountId' param").Error(), http.StatusBadRequest)
 			return
 		}
-		if account, appError = form3Client.GetAccount(accountId); appError.Error != nil {
-			http.Error(w, appError.Error.Error(), appError.Code)
+		if account, err = form3Client.GetAccount(r.Context(), accountId); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !auth.Allowed(r.Context(), account.Account.OrganisationID) {
+			writeError(w, r, accountNotFound(accountId))
 			return
 		}
+		traceAccount(r, account.Account)
 		if err = json.NewEncoder(w).Encode(account); err != nil {
 			http.Error(w, errors.Wrap(err, "Could not encode account into json").Error(), http.StatusInternalServerError)
 			return
@@ -36,6 +48,53 @@ func GetAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseW
 	}
 }
 
+func ListAccounts(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			options  form3_client.ListOptions
+			accounts []models.AccountData
+			links    models.Links
+			err      error
+		)
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+		if pageNumber := query.Get("page[number]"); len(pageNumber) != 0 {
+			if options.PageNumber, err = strconv.Atoi(pageNumber); err != nil || options.PageNumber < 0 {
+				http.Error(w, errors.Wrap(errors.New("validation"), "Invalid 'page[number]' param").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if pageSize := query.Get("page[size]"); len(pageSize) != 0 {
+			if options.PageSize, err = strconv.Atoi(pageSize); err != nil || options.PageSize <= 0 {
+				http.Error(w, errors.Wrap(errors.New("validation"), "Invalid 'page[size]' param").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		for key := range query {
+			if strings.HasPrefix(key, "filter[") && strings.HasSuffix(key, "]") {
+				if options.Filter == nil {
+					options.Filter = map[string]string{}
+				}
+				options.Filter[strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]")] = query.Get(key)
+			}
+		}
+		if err = authorizeList(r, &options); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		if accounts, links, err = form3Client.ListAccounts(r.Context(), options); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		accounts = allowedAccounts(r, accounts)
+		if err = json.NewEncoder(w).Encode(models.AccountListWrapper{Accounts: accounts, Links: links}); err != nil {
+			http.Error(w, errors.Wrap(err, "Could not encode accounts into json").Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 func DeleteAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -52,8 +111,25 @@ func DeleteAccount(form3Client form3_client.Form3ClientIface) func(w http.Respon
 			http.Error(w, errors.Wrap(errors.New("validation"), "Missing 'version' param").Error(), http.StatusBadRequest)
 			return
 		}
-		if appError := form3Client.DeleteAccount(accountId, version); appError.Error != nil {
-			http.Error(w, appError.Error.Error(), appError.Code)
+		if !authorizeAccount(w, r, form3Client, accountId) {
+			return
+		}
+		// version=latest opts in to deleting whatever version is current.
+		if version == latestVersion {
+			if err := form3Client.DeleteCurrentAccount(r.Context(), accountId); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		versionNumber, err := strconv.ParseInt(version, 10, 64)
+		if err != nil || versionNumber < 0 {
+			http.Error(w, errors.Wrap(errors.New("validation"), "Invalid 'version' param").Error(), http.StatusBadRequest)
+			return
+		}
+		if err = form3Client.DeleteAccount(r.Context(), accountId, versionNumber); err != nil {
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -64,21 +140,149 @@ func DeleteAccount(form3Client form3_client.Form3ClientIface) func(w http.Respon
 func CreateAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
-			account  models.AccountWrapper
-			err      error
-			appError models.AppError
+			request models.AccountWrapper
+			account models.AccountWrapper
+			body    []byte
+			err     error
 		)
 
 		w.Header().Set("Content-Type", "application/json")
-		if account, appError = form3Client.PostAccount(r.Body); appError.Error != nil {
-			http.Error(w, appError.Error.Error(), appError.Code)
+		if request, err = models.DecodeAccount(r.Body); err != nil {
+			writeDecodeError(w, r, err)
 			return
 		}
-		if err = json.NewEncoder(w).Encode(account); err != nil {
+		if id, ok := idempotentAccountID(r.Context()); ok && len(request.Account.ID) == 0 {
+			request.Account.ID = id
+		}
+		request.Account.SetDefaults()
+		traceAccount(r, request.Account)
+		if err = request.Account.Validate(); err != nil {
+			writeError(w, r, models.NewAppError(err, "Validation error", http.StatusBadRequest))
+			return
+		}
+		if !auth.Allowed(r.Context(), request.Account.OrganisationID) {
+			writeError(w, r, organisationForbidden(request.Account.OrganisationID))
+			return
+		}
+		if account, err = form3Client.CreateAccount(r.Context(), request.Account); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if body, err = json.Marshal(account); err != nil {
 			http.Error(w, errors.Wrap(err, "Could not encode account into json").Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}
+}
+
+// UpdateAccount patches the attributes of an account. The body carries the
+// version being updated, which form3 checks against the current one.
+func UpdateAccount(form3Client form3_client.Form3ClientIface) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			request models.AccountWrapper
+			account models.AccountWrapper
+			err     error
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		accountId, ok := mux.Vars(r)["accountId"]
+		if !ok {
+			http.Error(w, errors.Wrap(errors.New("validation"), "Missing 'accountId' param").Error(), http.StatusBadRequest)
+			return
+		}
+		if request, err = models.DecodeAccount(r.Body); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		if request.Account.Version == nil {
+			http.Error(w, errors.Wrap(errors.New("validation"), "Missing 'version' field").Error(), http.StatusBadRequest)
+			return
+		}
+		if len(request.Account.ID) != 0 && request.Account.ID != accountId {
+			http.Error(w, errors.Wrap(errors.New("validation"), "'id' field does not match 'accountId' param").Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Account.Attributes == nil {
+			http.Error(w, errors.Wrap(errors.New("validation"), "Missing 'attributes' field").Error(), http.StatusBadRequest)
+			return
+		}
+		if err = request.Account.Attributes.ValidateUpdate(); err != nil {
+			writeError(w, r, models.NewAppError(err, "Validation error", http.StatusBadRequest))
+			return
+		}
+		if !authorizeAccount(w, r, form3Client, accountId) {
+			return
+		}
+		if account, err = form3Client.UpdateAccount(r.Context(), accountId, *request.Account.Version, *request.Account.Attributes); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		traceAccount(r, account.Account)
+		if err = json.NewEncoder(w).Encode(account); err != nil {
+			http.Error(w, errors.Wrap(err, "Could not encode account into json").Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// writeError reports a failed client call with the HTTP status it carries.
+// Calls refused by the circuit breaker tell the caller when to come back, and
+// local validation failures are listed field by field as json.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		appError         *models.AppError
+		validationErrors models.ValidationErrors
+	)
+	logError(r, err)
+	traceError(r, err)
+	if errors.As(err, &appError) && appError.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appError.RetryAfter.Seconds()))))
+	}
+	if errors.As(err, &validationErrors) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(models.StatusCode(err))
+		json.NewEncoder(w).Encode(validationResponse{
+			ErrorMessage: "Validation error",
+			Errors:       validationErrors,
+		})
 		return
 	}
+	http.Error(w, err.Error(), models.StatusCode(err))
+}
+
+// writeDecodeError reports a request body that could not be decoded. A value
+// outside of an enum is reported like any other invalid field.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var enumError *models.InvalidEnumError
+	if errors.As(err, &enumError) {
+		writeError(w, r, models.NewAppError(models.ValidationErrors{enumError.FieldError()}, "Validation error", http.StatusBadRequest))
+		return
+	}
+	http.Error(w, errors.Wrap(err, "Could not decode account from json").Error(), http.StatusBadRequest)
+}
+
+// logError logs err with its kind, at error level when it is on the server side.
+func logError(r *http.Request, err error) {
+	var appError *models.AppError
+	entry := logging.FromContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+		"error_kind": models.ErrorKind(err),
+		"status":     models.StatusCode(err),
+	})
+	if errors.As(err, &appError) && len(appError.RequestID) != 0 {
+		entry = entry.WithField("upstream_request_id", appError.RequestID)
+	}
+	if models.IsServerError(err) {
+		entry.Error("Request failed")
+	} else {
+		entry.Warn("Request rejected")
+	}
+}
+
+type validationResponse struct {
+	ErrorMessage string                  `json:"error_message"`
+	Errors       models.ValidationErrors `json:"errors"`
 }
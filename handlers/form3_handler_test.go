@@ -1,6 +1,9 @@
 package handlers_test
 
 import (
+	"context"
+	"encoding/json"
+	form3_client "form3-interview/clients"
 	"form3-interview/handlers"
 	mock_form3_client "form3-interview/mocks"
 	"form3-interview/models"
@@ -11,7 +14,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_form3GetHandler(t *testing.T) {
@@ -24,6 +29,7 @@ func Test_form3GetHandler(t *testing.T) {
 		pathParam    map[string]string
 		mockShop     func(mock *mock_form3_client.MockForm3ClientIface)
 		status       int
+		retryAfter   string
 	}{
 		{
 			name:      "accountId, not provided",
@@ -35,15 +41,26 @@ func Test_form3GetHandler(t *testing.T) {
 			name:      "account not found",
 			pathParam: map[string]string{"accountId": ""},
 			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
-				mock.EXPECT().GetAccount(gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New(""), "", 404))
+				mock.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New(""), "", 404))
 			},
 			status: http.StatusNotFound,
 		},
+		{
+			name:      "circuit breaker open",
+			pathParam: map[string]string{"accountId": ""},
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				appError := models.NewAppError(models.ErrCircuitOpen, "Form3 server unavailable", 503)
+				appError.RetryAfter = 1500 * time.Millisecond
+				mock.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(models.AccountWrapper{}, appError)
+			},
+			status:     http.StatusServiceUnavailable,
+			retryAfter: "2",
+		},
 		{
 			name:      "happy path, account found",
 			pathParam: map[string]string{"accountId": ""},
 			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
-				mock.EXPECT().GetAccount(gomock.Any()).Return(mockedAccount(), models.AppError{})
+				mock.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(mockedAccount(), nil)
 			},
 			status: http.StatusOK,
 		},
@@ -69,6 +86,7 @@ func Test_form3GetHandler(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			assert.Equal(t, test.status, rr.Code)
+			assert.Equal(t, test.retryAfter, rr.Header().Get("Retry-After"))
 		})
 	}
 }
@@ -97,12 +115,37 @@ func Test_form3DeleteHandler(t *testing.T) {
 			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
 			status:    http.StatusBadRequest,
 		},
+		{
+			name:      "version, not a number",
+			pathParam: map[string]string{"accountId": "1234"},
+			version:   "one",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "version conflict",
+			pathParam: map[string]string{"accountId": "1234"},
+			version:   "0",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().DeleteAccount(gomock.Any(), "1234", int64(0)).Return(models.NewAppError(errors.New("invalid version"), "Validation error", 409))
+			},
+			status: http.StatusConflict,
+		},
 		{
 			name:      "happy path, deleted",
 			pathParam: map[string]string{"accountId": "1234"},
 			version:   "1",
 			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
-				mock.EXPECT().DeleteAccount(gomock.Any(), gomock.Any()).Return(models.AppError{})
+				mock.EXPECT().DeleteAccount(gomock.Any(), "1234", int64(1)).Return(nil)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:      "happy path, latest version deleted",
+			pathParam: map[string]string{"accountId": "1234"},
+			version:   "latest",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().DeleteCurrentAccount(gomock.Any(), "1234").Return(nil)
 			},
 			status: http.StatusNoContent,
 		},
@@ -139,22 +182,50 @@ func Test_form3PostHandler(t *testing.T) {
 
 	testCases := []struct {
 		name     string
+		body     string
 		mockShop func(mock *mock_form3_client.MockForm3ClientIface)
 		status   int
+		response string
 	}{
+		{
+			name:     "body is not json",
+			body:     "{data",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "classification is not one of the enum values",
+			body:     strings.Replace(mockedAccountJson(), `"country":"CA"`, `"account_classification":"personal","country":"CA"`, 1),
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:   http.StatusBadRequest,
+			response: "{\"error_message\":\"Validation error\",\"errors\":[" +
+				"{\"field\":\"attributes.account_classification\",\"message\":\"must be one of Personal, Business\"}]}\n",
+		},
+		{
+			name:     "account fails local validation",
+			body:     "{\"data\":{\"organisation_id\":\"1234\",\"attributes\":{\"country\":\"XX\"}}}",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:   http.StatusBadRequest,
+			response: "{\"error_message\":\"Validation error\",\"errors\":[" +
+				"{\"field\":\"organisation_id\",\"message\":\"must be a valid uuid\"}," +
+				"{\"field\":\"attributes.country\",\"message\":\"must be an ISO 3166-1 alpha-2 country code\"}," +
+				"{\"field\":\"attributes.name\",\"message\":\"is required\"}]}\n",
+		},
 		{
 			name: "unable to reach server",
+			body: mockedAccountJson(),
 			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
-				mock.EXPECT().PostAccount(gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New("unable to reach server"), "unable to reach server", 500))
+				mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New("unable to reach server"), "unable to reach server", 500))
 			},
 			status: http.StatusInternalServerError,
 		},
 		{
 			name: "happy path, created",
+			body: mockedAccountJson(),
 			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
-				mock.EXPECT().PostAccount(gomock.Any()).Return(mockedAccount(), models.AppError{})
+				mock.EXPECT().CreateAccount(gomock.Any(), mockedAccount().Account).Return(mockedAccount(), nil)
 			},
-			status: http.StatusOK,
+			status: http.StatusCreated,
 		},
 	}
 
@@ -162,7 +233,7 @@ func Test_form3PostHandler(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			req, err := http.NewRequest("POST", "/form3Client/accounts", nil)
+			req, err := http.NewRequest("POST", "/form3Client/accounts", strings.NewReader(test.body))
 			if err != nil {
 				t.Errorf("Error creating a new request: %v", err)
 			}
@@ -175,11 +246,199 @@ func Test_form3PostHandler(t *testing.T) {
 			handler := http.HandlerFunc(handlers.CreateAccount(mockClient))
 			handler.ServeHTTP(rr, req)
 
+			assert.Equal(t, test.status, rr.Code)
+			if len(test.response) != 0 {
+				assert.Equal(t, test.response, rr.Body.String())
+			}
+		})
+	}
+}
+
+func Test_form3PatchHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		pathParam map[string]string
+		body      string
+		mockShop  func(mock *mock_form3_client.MockForm3ClientIface)
+		status    int
+	}{
+		{
+			name:      "accountId, not provided",
+			pathParam: nil,
+			body:      "{\"data\":{\"version\":0,\"attributes\":{}}}",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "version, not provided",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"attributes\":{\"joint_account\":true}}}",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "id does not match accountId",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"id\":\"5678\",\"version\":0,\"attributes\":{\"joint_account\":true}}}",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "attributes, not provided",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"version\":0}}",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "attributes fail local validation",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"version\":0,\"attributes\":{\"country\":\"XX\"}}}",
+			mockShop:  func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "stale version",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"version\":0,\"attributes\":{\"joint_account\":true}}}",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().UpdateAccount(gomock.Any(), "1234", int64(0), gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New("invalid version"), "Validation error", 409))
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:      "happy path, updated",
+			pathParam: map[string]string{"accountId": "1234"},
+			body:      "{\"data\":{\"id\":\"1234\",\"version\":3,\"attributes\":{\"joint_account\":false}}}",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				joint := false
+				mock.EXPECT().UpdateAccount(gomock.Any(), "1234", int64(3), models.AccountAttributes{JointAccount: &joint}).Return(mockedAccount(), nil)
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest("PATCH", "/form3Client/accounts/", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("Error creating a new request: %v", err)
+			}
+			req = mux.SetURLVars(req, test.pathParam)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			rr := httptest.NewRecorder()
+			test.mockShop(mockClient)
+			handler := http.HandlerFunc(handlers.UpdateAccount(mockClient))
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, test.status, rr.Code)
+		})
+	}
+}
+
+func Test_form3ListHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		query    string
+		mockShop func(mock *mock_form3_client.MockForm3ClientIface)
+		status   int
+	}{
+		{
+			name:     "page number, not a number",
+			query:    "page[number]=abc",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "page size, not positive",
+			query:    "page[size]=0",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:  "unable to reach server",
+			query: "",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Return(nil, models.Links{}, models.NewAppError(errors.New("unable to reach server"), "unable to reach server", 500))
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:  "happy path, paging and filter forwarded",
+			query: "page[number]=1&page[size]=20&filter[country]=GB&other=ignored",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().ListAccounts(gomock.Any(), form3_client.ListOptions{
+					PageNumber: 1,
+					PageSize:   20,
+					Filter:     map[string]string{"country": "GB"},
+				}).Return([]models.AccountData{mockedAccount().Account}, models.Links{}, nil)
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest("GET", "/form3Client/accounts?"+test.query, nil)
+			if err != nil {
+				t.Fatalf("Error creating a new request: %v", err)
+			}
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			rr := httptest.NewRecorder()
+			test.mockShop(mockClient)
+			handler := http.HandlerFunc(handlers.ListAccounts(mockClient))
+			handler.ServeHTTP(rr, req)
+
 			assert.Equal(t, test.status, rr.Code)
 		})
 	}
 }
 
+func Test_form3HandlerPropagatesContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "/form3Client/accounts/1234", nil)
+	if err != nil {
+		t.Fatalf("Error creating a new request: %v", err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"accountId": "1234"})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	mockClient.EXPECT().GetAccount(gomock.Any(), "1234").DoAndReturn(func(ctx context.Context, accountId string) (models.AccountWrapper, error) {
+		assert.Equal(t, context.Canceled, ctx.Err())
+		return models.AccountWrapper{}, models.NewAppError(ctx.Err(), "Unable to reach form3 server", 500)
+	})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.GetAccount(mockClient)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func mockedAccountJson() string {
+	body, _ := json.Marshal(mockedAccount())
+	return string(body)
+}
+
 func mockedAccount() models.AccountWrapper {
 
 	query := gountries.New()
@@ -187,10 +446,15 @@ func mockedAccount() models.AccountWrapper {
 
 	return models.AccountWrapper{
 		Account: models.AccountData{
-			ID: "60c6add9-2b7b-4427-972a-8b272735562f",
+			ID:             "60c6add9-2b7b-4427-972a-8b272735562f",
+			OrganisationID: "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c",
+			Type:           "accounts",
 			Attributes: &models.AccountAttributes{
-				BankID:  "123456",
-				Country: &sweden.Alpha2,
+				BankID:     "012345678",
+				BankIDCode: "CACPA",
+				Bic:        "ROYCCAT2",
+				Country:    &sweden.Alpha2,
+				Name:       []string{"Samantha Holder"},
 			},
 		},
 	}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	form3_client "form3-interview/clients"
+	"form3-interview/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	statusUp       = "up"
+	statusDown     = "down"
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+)
+
+// ReadinessResponse is the body of /readyz.
+type ReadinessResponse struct {
+	Status   string         `json:"status"`
+	Upstream UpstreamHealth `json:"upstream"`
+}
+
+// UpstreamHealth is the outcome of the last probe of the form3 account API.
+type UpstreamHealth struct {
+	Status    string    `json:"status"`
+	ErrorKind string    `json:"error_kind,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Liveness answers 200 for as long as the process can serve requests.
+func Liveness() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": statusUp})
+	}
+}
+
+// Readiness answers 200 while the form3 account API reports itself up, and 503
+// otherwise. form3 is probed at most once every cacheFor, each probe giving up
+// after timeout; requests in between are answered from the last probe.
+func Readiness(form3Client form3_client.Form3ClientIface, timeout, cacheFor time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	var (
+		mu   sync.Mutex
+		last ReadinessResponse
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		response := last
+		if response.Upstream.CheckedAt.IsZero() || time.Since(response.Upstream.CheckedAt) >= cacheFor {
+			response = probe(r.Context(), form3Client, timeout)
+			// A probe cut short by the caller going away says nothing about form3.
+			if r.Context().Err() == nil {
+				last = response
+			}
+		}
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if response.Status != statusReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func probe(ctx context.Context, form3Client form3_client.Form3ClientIface, timeout time.Duration) ReadinessResponse {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	health, err := form3Client.Health(ctx)
+	upstream := UpstreamHealth{
+		Status:    health.Status,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		upstream.Status = statusDown
+		upstream.ErrorKind = models.ErrorKind(err)
+		upstream.Error = err.Error()
+	}
+	if upstream.Status != models.HealthUp {
+		return ReadinessResponse{Status: statusNotReady, Upstream: upstream}
+	}
+	return ReadinessResponse{Status: statusReady, Upstream: upstream}
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"form3-interview/handlers"
+	mock_form3_client "form3-interview/mocks"
+	"form3-interview/models"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_liveness(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Liveness()).ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"up"}`, rr.Body.String())
+}
+
+func Test_readiness(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		mockShop  func(mock *mock_form3_client.MockForm3ClientIface)
+		status    int
+		expected  handlers.ReadinessResponse
+		errorText string
+	}{
+		{
+			name: "form3 up",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().Health(gomock.Any()).Return(models.Health{Status: "up"}, nil)
+			},
+			status:   http.StatusOK,
+			expected: handlers.ReadinessResponse{Status: "ready", Upstream: handlers.UpstreamHealth{Status: "up"}},
+		},
+		{
+			name: "form3 reports itself down",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().Health(gomock.Any()).Return(models.Health{Status: "down"}, nil)
+			},
+			status:   http.StatusServiceUnavailable,
+			expected: handlers.ReadinessResponse{Status: "not_ready", Upstream: handlers.UpstreamHealth{Status: "down"}},
+		},
+		{
+			name: "form3 too slow",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().Health(gomock.Any()).DoAndReturn(func(ctx context.Context) (models.Health, error) {
+					<-ctx.Done()
+					return models.Health{}, models.NewAppError(ctx.Err(), "Timed out waiting for form3 server", 504)
+				})
+			},
+			status: http.StatusServiceUnavailable,
+			expected: handlers.ReadinessResponse{Status: "not_ready", Upstream: handlers.UpstreamHealth{
+				Status:    "down",
+				ErrorKind: "timeout",
+				Error:     "Timed out waiting for form3 server: context deadline exceeded",
+			}},
+		},
+		{
+			name: "circuit breaker open",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().Health(gomock.Any()).Return(models.Health{}, models.NewAppError(models.ErrCircuitOpen, "Circuit breaker is open", 503))
+			},
+			status: http.StatusServiceUnavailable,
+			expected: handlers.ReadinessResponse{Status: "not_ready", Upstream: handlers.UpstreamHealth{
+				Status:    "down",
+				ErrorKind: "circuit_open",
+				Error:     "Circuit breaker is open: circuit breaker is open",
+			}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			test.mockShop(mockClient)
+
+			handler := http.HandlerFunc(handlers.Readiness(mockClient, 10*time.Millisecond, time.Minute))
+			for i := 0; i < 2; i++ {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+				var response handlers.ReadinessResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+				assert.Equal(t, test.status, rr.Code)
+				assert.False(t, response.Upstream.CheckedAt.IsZero())
+				response.Upstream.CheckedAt = time.Time{}
+				response.Upstream.LatencyMs = 0
+				assert.Equal(t, test.expected, response)
+			}
+		})
+	}
+}
+
+func Test_readinessProbesAgainOnceStale(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	gomock.InOrder(
+		mockClient.EXPECT().Health(gomock.Any()).Return(models.Health{}, models.NewAppError(errors.New("connection refused"), "Unable to reach form3 server", 500)),
+		mockClient.EXPECT().Health(gomock.Any()).Return(models.Health{Status: "up"}, nil),
+	)
+
+	handler := http.HandlerFunc(handlers.Readiness(mockClient, time.Second, 0))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"form3-interview/auth"
+	form3_client "form3-interview/clients"
+	"form3-interview/logging"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotentReplayedHeader is set on responses replayed from the store.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// StoredResponse is the response remembered for an idempotency key, along with
+// a hash of the request body it answered.
+type StoredResponse struct {
+	RequestHash string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+type IdempotencyStore interface {
+	Get(key string) (StoredResponse, bool)
+	Put(key string, response StoredResponse)
+}
+
+// MemoryIdempotencyStore keeps responses in memory for a fixed time.
+type MemoryIdempotencyStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	responses map[string]storedEntry
+}
+
+type storedEntry struct {
+	response  StoredResponse
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:       ttl,
+		responses: map[string]storedEntry{},
+	}
+}
+
+func (s *MemoryIdempotencyStore) Get(key string) (StoredResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.responses[key]
+	if !ok {
+		return StoredResponse{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.responses, key)
+		return StoredResponse{}, false
+	}
+	return entry.response, true
+}
+
+func (s *MemoryIdempotencyStore) Put(key string, response StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for storedKey, entry := range s.responses {
+		if now.After(entry.expiresAt) {
+			delete(s.responses, storedKey)
+		}
+	}
+	s.responses[key] = storedEntry{response: response, expiresAt: now.Add(s.ttl)}
+}
+
+// Idempotent makes next safe to replay for requests carrying an
+// Idempotency-Key header. The first response to a key is remembered, unless
+// it is a server error, and replayed to every later request with the same key
+// without calling next again. Reusing a key for a different body is rejected
+// with 422, and a key whose first request is still running with 409.
+//
+// The key is also handed to the form3 client through the request context, so
+// the upstream call can be retried safely.
+func Idempotent(store IdempotencyStore, next http.HandlerFunc) func(w http.ResponseWriter, r *http.Request) {
+	var (
+		mu       sync.Mutex
+		inFlight = map[string]bool{}
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(form3_client.IdempotencyKeyHeader)
+		if len(key) == 0 {
+			next(w, r)
+			return
+		}
+		storeKey := scopedKey(r.Context(), key)
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, errors.Wrap(err, "Could not read request body").Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+
+		mu.Lock()
+		stored, found := store.Get(storeKey)
+		if !found && inFlight[storeKey] {
+			mu.Unlock()
+			http.Error(w, errors.Wrap(errors.New("idempotency"), "A request with this 'Idempotency-Key' is in progress").Error(), http.StatusConflict)
+			return
+		}
+		if !found {
+			inFlight[storeKey] = true
+		}
+		mu.Unlock()
+
+		if found {
+			if stored.RequestHash != requestHash {
+				http.Error(w, errors.Wrap(errors.New("idempotency"), "'Idempotency-Key' was used for a different request").Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		defer func() {
+			mu.Lock()
+			delete(inFlight, storeKey)
+			mu.Unlock()
+		}()
+		recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(form3_client.WithIdempotencyKey(r.Context(), key)))
+		if recorder.status < http.StatusInternalServerError {
+			// The request id belongs to the request answered, not to the
+			// ones the response is replayed to.
+			header := w.Header().Clone()
+			header.Del(logging.RequestIDHeader)
+			store.Put(storeKey, StoredResponse{
+				RequestHash: requestHash,
+				Status:      recorder.status,
+				Header:      header,
+				Body:        recorder.body.Bytes(),
+			})
+		}
+	}
+}
+
+// scopedKey scopes an idempotency key to the caller of ctx, so that nobody is
+// replayed the response to someone else.
+func scopedKey(ctx context.Context, key string) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return principal.Name + "\x00" + key
+	}
+	return key
+}
+
+// idempotentAccountID returns the id of the account created under the
+// idempotency key carried by ctx, scoped to its caller so that two callers
+// using the same key do not create the same account.
+func idempotentAccountID(ctx context.Context) (string, bool) {
+	key, ok := form3_client.IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return form3_client.IdempotentAccountID(scopedKey(ctx, key)), true
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(body []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(body)
+	return w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,197 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	form3_client "form3-interview/clients"
+	"form3-interview/handlers"
+	"form3-interview/logging"
+	mock_form3_client "form3-interview/mocks"
+	"form3-interview/models"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_idempotentCreateHandler(t *testing.T) {
+	t.Parallel()
+
+	type call struct {
+		key          string
+		body         string
+		status       int
+		replayed     bool
+		expectedBody string
+	}
+
+	otherAccount := strings.Replace(mockedAccountJson(), "Samantha Holder", "Sam Holder", 1)
+	testCases := []struct {
+		name     string
+		mockShop func(mock *mock_form3_client.MockForm3ClientIface)
+		calls    []call
+	}{
+		{
+			name: "replayed POST returns the original 201 body",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, accountData models.AccountData) (models.AccountWrapper, error) {
+					key, ok := form3_client.IdempotencyKeyFromContext(ctx)
+					assert.True(t, ok)
+					assert.Equal(t, "key-1", key)
+					return mockedAccount(), nil
+				}).Times(1)
+			},
+			calls: []call{
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusCreated, expectedBody: mockedAccountJson()},
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusCreated, replayed: true, expectedBody: mockedAccountJson()},
+			},
+		},
+		{
+			name: "key reused for a different body",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(mockedAccount(), nil).Times(1)
+			},
+			calls: []call{
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusCreated},
+				{key: "key-1", body: otherAccount, status: http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			name: "server errors are not remembered",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				gomock.InOrder(
+					mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(models.AccountWrapper{}, models.NewAppError(errors.New("timeout"), "Unable to reach form3 server", 500)),
+					mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(mockedAccount(), nil),
+				)
+			},
+			calls: []call{
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusInternalServerError},
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusCreated},
+				{key: "key-1", body: mockedAccountJson(), status: http.StatusCreated, replayed: true},
+			},
+		},
+		{
+			name: "requests without a key are not remembered",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, accountData models.AccountData) (models.AccountWrapper, error) {
+					_, ok := form3_client.IdempotencyKeyFromContext(ctx)
+					assert.False(t, ok)
+					return mockedAccount(), nil
+				}).Times(2)
+			},
+			calls: []call{
+				{body: mockedAccountJson(), status: http.StatusCreated},
+				{body: mockedAccountJson(), status: http.StatusCreated},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			test.mockShop(mockClient)
+			handler := http.HandlerFunc(handlers.Idempotent(handlers.NewMemoryIdempotencyStore(time.Minute), handlers.CreateAccount(mockClient)))
+
+			for _, call := range test.calls {
+				req, err := http.NewRequest("POST", "/form3Client/accounts", strings.NewReader(call.body))
+				if err != nil {
+					t.Fatalf("Error creating a new request: %v", err)
+				}
+				if len(call.key) != 0 {
+					req.Header.Set("Idempotency-Key", call.key)
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				assert.Equal(t, call.status, rr.Code)
+				assert.Equal(t, call.replayed, rr.Header().Get("Idempotent-Replayed") == "true")
+				if len(call.expectedBody) != 0 {
+					assert.JSONEq(t, call.expectedBody, rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_memoryIdempotencyStoreExpires(t *testing.T) {
+	t.Parallel()
+
+	store := handlers.NewMemoryIdempotencyStore(20 * time.Millisecond)
+	store.Put("key-1", handlers.StoredResponse{Status: http.StatusCreated})
+
+	_, found := store.Get("key-1")
+	assert.True(t, found)
+	time.Sleep(30 * time.Millisecond)
+	_, found = store.Get("key-1")
+	assert.False(t, found)
+}
+
+func Test_idempotentCreateRetriedAfterServerError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := mockedAccount()
+	account.Account.ID = ""
+	body, _ := json.Marshal(account)
+
+	var ids []string
+	recordID := func(ctx context.Context, accountData models.AccountData) {
+		ids = append(ids, accountData.ID)
+	}
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	gomock.InOrder(
+		mockClient.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Do(recordID).
+			Return(models.AccountWrapper{}, models.NewAppError(context.DeadlineExceeded, "Unable to reach form3 server", http.StatusGatewayTimeout)),
+		mockClient.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Do(recordID).Return(mockedAccount(), nil),
+	)
+	handler := http.HandlerFunc(handlers.Idempotent(handlers.NewMemoryIdempotencyStore(time.Minute), handlers.CreateAccount(mockClient)))
+
+	for _, status := range []int{http.StatusGatewayTimeout, http.StatusCreated} {
+		req := httptest.NewRequest("POST", "/form3Client/accounts", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", "key-1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code)
+	}
+
+	if assert.Len(t, ids, 2) {
+		assert.NotEmpty(t, ids[0])
+		assert.Equal(t, ids[0], ids[1], "the retry must create the same account")
+	}
+}
+
+func Test_idempotentReplayEchoesItsOwnRequestID(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	mockClient.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(mockedAccount(), nil).Times(1)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	handler := handlers.RequestLogging(logger)(http.HandlerFunc(
+		handlers.Idempotent(handlers.NewMemoryIdempotencyStore(time.Minute), handlers.CreateAccount(mockClient))))
+
+	for _, requestID := range []string{"first", "second"} {
+		req := httptest.NewRequest("POST", "/form3Client/accounts", strings.NewReader(mockedAccountJson()))
+		req.Header.Set("Idempotency-Key", "key-1")
+		req.Header.Set(logging.RequestIDHeader, requestID)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, requestID, rr.Header().Get(logging.RequestIDHeader))
+	}
+}
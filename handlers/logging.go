@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"form3-interview/logging"
+	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"time"
+)
+
+const maxRequestIDLength = 128
+
+// RequestLogging tags every request with a correlation id, read from the
+// X-Request-ID header or generated, and echoes it in the response. The id and
+// a logger carrying it are passed down through the request context, so the
+// form3 client forwards the id and logs under it. Once served, the request is
+// logged with its method, path, status and latency.
+func RequestLogging(logger *logrus.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := r.Header.Get(logging.RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.New()
+			}
+			w.Header().Set(logging.RequestIDHeader, requestID)
+
+			entry := logger.WithField("request_id", requestID)
+			if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.IsValid() {
+				entry = entry.WithField("trace_id", spanContext.TraceID().String())
+			}
+			ctx := logging.WithLogger(logging.WithRequestID(r.Context(), requestID), entry)
+			recorder := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			entry = entry.WithFields(logrus.Fields{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"status":     recorder.status,
+				"latency_ms": time.Since(start).Milliseconds(),
+			})
+			if recorder.status >= http.StatusInternalServerError {
+				entry.Error("Request served")
+			} else {
+				entry.Info("Request served")
+			}
+		})
+	}
+}
+
+// validRequestID accepts ids of printable ASCII that are short enough to log.
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, char := range requestID {
+		if char <= ' ' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter remembers the status written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(body []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"form3-interview/handlers"
+	"form3-interview/logging"
+	mock_form3_client "form3-interview/mocks"
+	"form3-interview/models"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_requestLogging(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		requestID     string
+		keptRequestID bool
+		status        int
+		level         logrus.Level
+	}{
+		{
+			name:          "request id read from the header",
+			requestID:     "4ff753ac-bc01-46c5-ad54-055aaaef5a00",
+			keptRequestID: true,
+			status:        http.StatusOK,
+			level:         logrus.InfoLevel,
+		},
+		{
+			name:   "request id generated",
+			status: http.StatusNotFound,
+			level:  logrus.InfoLevel,
+		},
+		{
+			name:      "invalid request id replaced",
+			requestID: strings.Repeat("a", 129),
+			status:    http.StatusBadGateway,
+			level:     logrus.ErrorLevel,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			logger, hook := logtest.NewNullLogger()
+
+			var seenRequestID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenRequestID, _ = logging.RequestIDFromContext(r.Context())
+				w.WriteHeader(test.status)
+			})
+			req := httptest.NewRequest("GET", "/form3Client/accounts/1234", nil)
+			if len(test.requestID) != 0 {
+				req.Header.Set(logging.RequestIDHeader, test.requestID)
+			}
+			rr := httptest.NewRecorder()
+			handlers.RequestLogging(logger)(next).ServeHTTP(rr, req)
+
+			requestID := rr.Header().Get(logging.RequestIDHeader)
+			assert.NotEmpty(t, requestID)
+			assert.Equal(t, requestID, seenRequestID)
+			assert.Equal(t, test.keptRequestID, requestID == test.requestID)
+
+			entry := hook.LastEntry()
+			assert.Equal(t, test.level, entry.Level)
+			assert.Equal(t, requestID, entry.Data["request_id"])
+			assert.Equal(t, "GET", entry.Data["method"])
+			assert.Equal(t, "/form3Client/accounts/1234", entry.Data["path"])
+			assert.Equal(t, test.status, entry.Data["status"])
+			assert.Contains(t, entry.Data, "latency_ms")
+		})
+	}
+}
+
+func Test_requestLoggingErrorKind(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+	mockClient.EXPECT().GetAccount(gomock.Any(), "1234").Return(models.AccountWrapper{}, models.NewAppError(errors.New("record does not exist"), "Validation error", http.StatusNotFound))
+
+	logger, hook := logtest.NewNullLogger()
+	handler := handlers.RequestLogging(logger)(http.HandlerFunc(handlers.GetAccount(mockClient)))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/form3Client/accounts/1234", nil), map[string]string{"accountId": "1234"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "not_found", entries[0].Data["error_kind"])
+		assert.Equal(t, logrus.WarnLevel, entries[0].Level)
+		assert.Equal(t, entries[1].Data["request_id"], entries[0].Data["request_id"])
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"form3-interview/metrics"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// unmatchedRoute labels requests that did not match a route of the router.
+const unmatchedRoute = "unmatched"
+
+// RequestMetrics records every request in m, labelled by the path template of
+// the route it matched so that account ids do not end up in labels.
+func RequestMetrics(m *metrics.Metrics) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			route := unmatchedRoute
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			m.RequestStarted()
+			recorder := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				m.RequestFinished(route, r.Method, recorder.status, time.Since(start))
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
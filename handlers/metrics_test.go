@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"form3-interview/handlers"
+	"form3-interview/metrics"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_requestMetrics(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	router := mux.NewRouter()
+	router.Use(handlers.RequestMetrics(metrics.New(registry)))
+	router.HandleFunc("/form3Client/accounts/{accountId}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["accountId"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods(http.MethodGet)
+
+	for _, accountId := range []string{"1234", "5678", "missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/form3Client/accounts/"+accountId, nil))
+	}
+
+	expected := `
+# HELP form3_proxy_requests_in_flight Requests being served.
+# TYPE form3_proxy_requests_in_flight gauge
+form3_proxy_requests_in_flight 0
+# HELP form3_proxy_requests_total Requests served, by route, method and status.
+# TYPE form3_proxy_requests_total counter
+form3_proxy_requests_total{method="GET",route="/form3Client/accounts/{accountId}",status="200"} 2
+form3_proxy_requests_total{method="GET",route="/form3Client/accounts/{accountId}",status="404"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "form3_proxy_requests_in_flight", "form3_proxy_requests_total"))
+}
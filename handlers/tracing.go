@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"form3-interview/models"
+	"form3-interview/tracing"
+	"github.com/gorilla/mux"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Tracing serves every request under a span continuing the trace of its
+// traceparent header, if any. The span records the route, the account id of
+// the path and the status; handlers add the organisation of the account and
+// the kind of error they fail with.
+func Tracing(provider trace.TracerProvider) func(next http.Handler) http.Handler {
+	tracer := tracing.Tracer(provider)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := unmatchedRoute
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, r)...),
+			)
+			defer span.End()
+			if accountId, ok := mux.Vars(r)["accountId"]; ok {
+				span.SetAttributes(tracing.AccountIDKey.String(accountId))
+			}
+
+			recorder := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(recorder.status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(recorder.status, trace.SpanKindServer))
+		})
+	}
+}
+
+// traceAccount records the account a request is about on its span.
+func traceAccount(r *http.Request, account models.AccountData) {
+	span := trace.SpanFromContext(r.Context())
+	if len(account.ID) != 0 {
+		span.SetAttributes(tracing.AccountIDKey.String(account.ID))
+	}
+	if len(account.OrganisationID) != 0 {
+		span.SetAttributes(tracing.OrganisationIDKey.String(account.OrganisationID))
+	}
+}
+
+// traceError records err and its kind on the span of the request.
+func traceError(r *http.Request, err error) {
+	span := trace.SpanFromContext(r.Context())
+	span.RecordError(err)
+	span.SetAttributes(tracing.ErrorKindKey.String(models.ErrorKind(err)))
+}
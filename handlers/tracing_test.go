@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"form3-interview/handlers"
+	mock_form3_client "form3-interview/mocks"
+	"form3-interview/models"
+	"form3-interview/tracing"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_tracing(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		accountId  string
+		mockShop   func(mock *mock_form3_client.MockForm3ClientIface)
+		attributes []attribute.KeyValue
+		status     codes.Code
+	}{
+		{
+			name:      "account found",
+			accountId: "60c6add9-2b7b-4427-972a-8b272735562f",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "60c6add9-2b7b-4427-972a-8b272735562f").Return(mockedAccount(), nil)
+			},
+			attributes: []attribute.KeyValue{
+				tracing.AccountIDKey.String("60c6add9-2b7b-4427-972a-8b272735562f"),
+				tracing.OrganisationIDKey.String("eb0bd6f5-c3f5-44b2-b677-acd23cdde73c"),
+				attribute.Int("http.status_code", http.StatusOK),
+			},
+			status: codes.Unset,
+		},
+		{
+			name:      "form3 unreachable",
+			accountId: "1234",
+			mockShop: func(mock *mock_form3_client.MockForm3ClientIface) {
+				mock.EXPECT().GetAccount(gomock.Any(), "1234").Return(models.AccountWrapper{}, models.NewAppError(errors.New("connection refused"), "Unable to reach form3 server", 500))
+			},
+			attributes: []attribute.KeyValue{
+				tracing.AccountIDKey.String("1234"),
+				tracing.ErrorKindKey.String("server_error"),
+				attribute.Int("http.status_code", http.StatusInternalServerError),
+			},
+			status: codes.Error,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockClient := mock_form3_client.NewMockForm3ClientIface(ctrl)
+			test.mockShop(mockClient)
+
+			recorder := tracetest.NewSpanRecorder()
+			router := mux.NewRouter()
+			router.Use(handlers.Tracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+			router.HandleFunc("/form3Client/accounts/{accountId}", handlers.GetAccount(mockClient)).Methods(http.MethodGet)
+
+			req := httptest.NewRequest("GET", "/form3Client/accounts/"+test.accountId, nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if !assert.Len(t, spans, 1) {
+				return
+			}
+			span := spans[0]
+			assert.Equal(t, "GET /form3Client/accounts/{accountId}", span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+			assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+			assert.Subset(t, span.Attributes(), test.attributes)
+			assert.Equal(t, test.status, span.Status().Code)
+		})
+	}
+}
@@ -2,9 +2,13 @@ package it_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	form3_client "form3-interview/clients"
+	"form3-interview/models"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
 	"os"
@@ -34,13 +38,13 @@ func Test_form3ClientGet(t *testing.T) {
 			name:      "Account id is not uuid",
 			accountId: "4567",
 			status:    http.StatusBadRequest,
-			err:       errors.New("{\"error_message\":\"id is not a valid uuid\"}"),
+			err:       errors.New("id is not a valid uuid"),
 		},
 		{
 			name:      "Account doesn't exist",
 			accountId: "02d3792a-1c45-4d91-98d0-ca83790afe89",
 			status:    http.StatusNotFound,
-			err:       errors.New("{\"error_message\":\"record 02d3792a-1c45-4d91-98d0-ca83790afe89 does not exist\"}"),
+			err:       errors.New("record 02d3792a-1c45-4d91-98d0-ca83790afe89 does not exist"),
 		},
 		{
 			name:      "happy path, account retrieved",
@@ -59,13 +63,17 @@ func Test_form3ClientGet(t *testing.T) {
 				createDummyAccount(test.accountId)
 			}
 
-			account, err := client.GetAccount(test.accountId)
+			account, err := client.GetAccount(context.Background(), test.accountId)
 			if test.err == nil {
 				assert.Equal(t, account.Account.ID, test.accountId)
 				deleteDummyAccount(test.accountId)
 			} else {
-				assert.Equal(t, test.err.Error(), err.Error.Error())
-				assert.Equal(t, test.status, err.Code)
+				appError := appErrorOf(t, err)
+				require.NotNil(t, appError.Form3Error, "expected a form3 error body, got %v", err)
+				assert.Equal(t, test.err.Error(), appError.Form3Error.ErrorMessage)
+				assert.Equal(t, test.status, appError.Code)
+				assert.Equal(t, test.status == http.StatusNotFound, models.IsNotFound(err))
+				assert.Equal(t, test.status == http.StatusBadRequest, models.IsValidation(err))
 			}
 		})
 	}
@@ -81,12 +89,6 @@ func Test_form3ClientPost(t *testing.T) {
 		status       int
 		accountId    string
 	}{
-		{
-			name:         "Missing required field, organization_id",
-			givenPayload: strings.NewReader("{}"),
-			err:          errors.New("Validation error"),
-			status:       http.StatusInternalServerError,
-		},
 		{
 			name:         "Bad data sent to server",
 			givenPayload: strings.NewReader(""),
@@ -107,52 +109,67 @@ func Test_form3ClientPost(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			account, err := client.PostAccount(test.givenPayload)
+			account, err := client.PostAccount(context.Background(), test.givenPayload)
 			if test.err == nil {
 				assert.Equal(t, account.Account.ID, test.accountId)
 				deleteDummyAccount(test.accountId)
 			} else {
-				assert.Equal(t, test.err.Error(), err.Message)
-				assert.Equal(t, test.status, err.Code)
+				appError := appErrorOf(t, err)
+				assert.Equal(t, test.err.Error(), appError.Message)
+				assert.Equal(t, test.status, appError.Code)
 			}
 		})
 	}
 }
 
+func Test_form3ClientCreateValidatesLocally(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	local := client
+	local.HttpClient = &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	_, err := local.CreateAccount(context.Background(), models.AccountData{})
+	appError := appErrorOf(t, err)
+	assert.Equal(t, "Validation error", appError.Message)
+	assert.Equal(t, http.StatusBadRequest, appError.Code)
+	var validationErrors models.ValidationErrors
+	require.True(t, errors.As(err, &validationErrors), "expected ValidationErrors, got %v", err)
+	assert.Contains(t, validationErrors.Error(), "organisation_id")
+	assert.Zero(t, calls, "an invalid account must not reach form3")
+}
+
 func Test_form3ClientDelete(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
 		name      string
 		accountId string
-		version   string
+		version   int64
 		err       error
 		code      int
 	}{
 		{
 			name:      "Missing required field, account_id and version",
 			accountId: "",
-			version:   "",
-			err:       errors.New("{\"code\":\"PAGE_NOT_FOUND\",\"message\":\"Page not found\"}"),
+			version:   0,
+			err:       errors.New("Page not found"),
 			code:      http.StatusNotFound,
 		},
 		{
 			name:      "Account id not uuid",
 			accountId: "1234",
-			version:   "0",
-			err:       errors.New("{\"error_message\":\"id is not a valid uuid\"}"),
-			code:      http.StatusBadRequest,
-		},
-		{
-			name:      "Missing required field, version",
-			accountId: "cb1e2074-1056-4b27-b4e0-ed9f0c46b067",
-			err:       errors.New("{\"error_message\":\"invalid version number\"}"),
+			version:   0,
+			err:       errors.New("id is not a valid uuid"),
 			code:      http.StatusBadRequest,
 		},
 		{
 			name:      "happy path, account deleted",
 			accountId: "ac48f757-ac69-4257-ac6f-479763c8432e",
-			version:   "0",
+			version:   0,
 			err:       nil,
 		},
 	}
@@ -165,10 +182,12 @@ func Test_form3ClientDelete(t *testing.T) {
 			if test.name == "happy path, account deleted" {
 				createDummyAccount(test.accountId)
 			}
-			err := client.DeleteAccount(test.accountId, test.version)
-			if err.Error != nil {
-				assert.Equal(t, test.err.Error(), err.Error.Error())
-				assert.Equal(t, test.code, err.Code)
+			err := client.DeleteAccount(context.Background(), test.accountId, test.version)
+			if err != nil {
+				appError := appErrorOf(t, err)
+				require.NotNil(t, appError.Form3Error, "expected a form3 error body, got %v", err)
+				assert.Equal(t, test.err.Error(), appError.Form3Error.ErrorMessage)
+				assert.Equal(t, test.code, appError.Code)
 			} else {
 				deleteDummyAccount(test.accountId)
 			}
@@ -176,18 +195,58 @@ func Test_form3ClientDelete(t *testing.T) {
 	}
 }
 
+func Test_form3ClientDeleteCurrent(t *testing.T) {
+	t.Parallel()
+
+	accountId := "3c4b5a1e-9f3a-4b4e-8b0c-1f6f2a9d7e21"
+	createDummyAccount(accountId)
+
+	err := client.DeleteCurrentAccount(context.Background(), accountId)
+	assert.NoError(t, err)
+
+	_, err = client.GetAccount(context.Background(), accountId)
+	assert.True(t, models.IsNotFound(err))
+}
+
 func createDummyAccount(accountId string) {
 	body := getBody(accountId)
-	client.PostAccount(body)
+	client.PostAccount(context.Background(), body)
 }
 
 func getBody(accountId string) io.Reader {
-	byteBody := []byte("{\n    \"data\": {\n        \"attributes\": {\n            \"account_classification\": \"Personal\",\n            \"account_matching_opt_out\": false,\n            \"alternative_names\": [\n                \"Sam Holder\"\n            ],\n            \"bank_id\": \"400300\",\n            \"bank_id_code\": \"GBDSC\",\n            \"base_currency\": \"GBP\",\n            \"bic\": \"NWBKGB22\",\n            \"country\": \"GB\",\n            \"joint_account\": false,\n            \"name\": [\n                \"Samantha Holder\"\n            ],\n            \"secondary_identification\": \"A1B2C3D4\"\n        },\n        \"id\": \"" + accountId + "\" ,\n        \"organisation_id\": \"eb0bd6f5-c3f5-44b2-b677-acd23cdde73c\",\n        \"type\": \"accounts\",\n        \"version\": 0\n    }\n}")
-	return bytes.NewReader(byteBody)
+	account, err := models.GBAccount("eb0bd6f5-c3f5-44b2-b677-acd23cdde73c").
+		WithID(accountId).
+		WithVersion(0).
+		WithBank(models.BankIDCodeGB, "400300", "NWBKGB22").
+		WithNames("Samantha Holder").
+		WithAlternativeNames("Sam Holder").
+		WithClassification(models.Personal).
+		WithSecondaryIdentification("A1B2C3D4").
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	body, _ := json.Marshal(account)
+	return bytes.NewReader(body)
 }
 
 func deleteDummyAccount(accountId string) {
-	client.DeleteAccount(accountId, "0")
+	client.DeleteAccount(context.Background(), accountId, 0)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func appErrorOf(t *testing.T, err error) *models.AppError {
+	t.Helper()
+	var appError *models.AppError
+	if !errors.As(err, &appError) {
+		t.Fatalf("expected an AppError, got %v", err)
+	}
+	return appError
 }
 
 func getEnv(key, fallback string) string {
@@ -0,0 +1,87 @@
+// Package logging sets up the structured logger of the service and carries the
+// request correlation id, and a logger tagged with it, through contexts.
+package logging
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strings"
+)
+
+// RequestIDHeader carries the correlation id of a request, both on inbound
+// requests and responses and on the calls made to form3 on their behalf.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// New returns a logger writing to stderr at level ("debug", "info", "warn",
+// "error", ...) in format, FormatJSON or FormatText. Invalid settings fall
+// back to info and json, and are reported by the returned error.
+func New(level, format string) (*logrus.Logger, error) {
+	var err error
+	logger := logrus.New()
+	logger.Out = os.Stderr
+
+	parsedLevel, levelErr := logrus.ParseLevel(level)
+	if levelErr != nil {
+		parsedLevel = logrus.InfoLevel
+		err = errors.Wrapf(levelErr, "Invalid log level %q", level)
+	}
+	logger.SetLevel(parsedLevel)
+
+	switch strings.ToLower(format) {
+	case FormatText:
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON, "":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		if err == nil {
+			err = errors.Errorf("Invalid log format %q, expected %q or %q", format, FormatJSON, FormatText)
+		}
+	}
+	return logger, err
+}
+
+// WithRequestID returns a copy of ctx carrying the correlation id.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the correlation id carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok && len(requestID) != 0
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the standard logrus logger
+// when there is none. Either way it is tagged with the correlation id of ctx.
+func FromContext(ctx context.Context) *logrus.Entry {
+	logger, ok := ctx.Value(loggerKey).(*logrus.Entry)
+	if !ok {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		if _, tagged := logger.Data["request_id"]; !tagged {
+			logger = logger.WithField("request_id", requestID)
+		}
+	}
+	return logger
+}
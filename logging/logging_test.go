@@ -0,0 +1,97 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"form3-interview/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_new(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		level         string
+		format        string
+		expectedLevel logrus.Level
+		expectedJSON  bool
+		err           string
+	}{
+		{
+			name:          "debug in text",
+			level:         "debug",
+			format:        "text",
+			expectedLevel: logrus.DebugLevel,
+		},
+		{
+			name:          "warn in json",
+			level:         "WARN",
+			format:        "json",
+			expectedLevel: logrus.WarnLevel,
+			expectedJSON:  true,
+		},
+		{
+			name:          "invalid level",
+			level:         "loud",
+			format:        "json",
+			expectedLevel: logrus.InfoLevel,
+			expectedJSON:  true,
+			err:           "Invalid log level \"loud\"",
+		},
+		{
+			name:          "invalid format",
+			level:         "info",
+			format:        "xml",
+			expectedLevel: logrus.InfoLevel,
+			expectedJSON:  true,
+			err:           "Invalid log format \"xml\", expected \"json\" or \"text\"",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			logger, err := logging.New(test.level, test.format)
+			if len(test.err) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.True(t, strings.HasPrefix(err.Error(), test.err))
+			}
+			assert.Equal(t, test.expectedLevel, logger.GetLevel())
+			_, isJSON := logger.Formatter.(*logrus.JSONFormatter)
+			assert.Equal(t, test.expectedJSON, isJSON)
+		})
+	}
+}
+
+func Test_fromContext(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger, _ := logging.New("info", "json")
+	logger.Out = &out
+
+	ctx := logging.WithLogger(context.Background(), logger.WithField("component", "test"))
+	ctx = logging.WithRequestID(ctx, "4ff753ac")
+	requestID, ok := logging.RequestIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "4ff753ac", requestID)
+
+	logging.FromContext(ctx).Info("hello")
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "4ff753ac", line["request_id"])
+	assert.Equal(t, "test", line["component"])
+	assert.Equal(t, "hello", line["msg"])
+
+	_, ok = logging.RequestIDFromContext(context.Background())
+	assert.False(t, ok)
+	assert.NotNil(t, logging.FromContext(context.Background()))
+}
@@ -1,10 +1,30 @@
 package main
 
 import (
+	"flag"
 	"form3-interview/app"
+	"form3-interview/config"
+	"github.com/pkg/errors"
+	"log"
+	"os"
 )
 
 func main() {
-	app := app.App{}
-	app.HandleRequests()
+	appConfig, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	application, err := app.NewApp(appConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	application.Logger.WithFields(appConfig.Fields()).Info("Loaded configuration")
+	if err = application.Run(); err != nil {
+		application.Logger.WithError(err).Fatal("Server failed")
+	}
+	application.Logger.Info("Server stopped")
 }
@@ -0,0 +1,149 @@
+// Package metrics holds the Prometheus metrics of the proxy: the requests it
+// serves and the calls it makes to form3 on their behalf.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+const namespace = "form3_proxy"
+
+// Metrics records what the proxy does. A nil *Metrics records nothing, so
+// components can be used without metrics.
+type Metrics struct {
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	requestsInFlight  prometheus.Gauge
+	upstreamCalls     *prometheus.CounterVec
+	upstreamDuration  *prometheus.HistogramVec
+	upstreamInFlight  prometheus.Gauge
+	upstreamRetries   *prometheus.CounterVec
+	breakerRejections prometheus.Counter
+	breakerState      prometheus.Gauge
+}
+
+// New creates the metrics and registers them with registerer. It panics if
+// they are already registered there.
+func New(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Requests served, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve requests, by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "requests_in_flight",
+			Help:      "Requests being served.",
+		}),
+		upstreamCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Calls made to form3, by method, status and error kind. Status is empty when form3 could not be reached.",
+		}, []string{"method", "status", "error_kind"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Time taken by calls to form3, by method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "status"}),
+		upstreamInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_in_flight",
+			Help:      "Calls to form3 waiting for a response.",
+		}),
+		upstreamRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_retries_total",
+			Help:      "Calls to form3 retried after a transient failure, by method.",
+		}, []string{"method"}),
+		breakerRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_rejections_total",
+			Help:      "Calls to form3 refused because the circuit breaker was open.",
+		}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker: 0 closed, 1 open, 2 half-open.",
+		}),
+	}
+	registerer.MustRegister(
+		m.requests, m.requestDuration, m.requestsInFlight,
+		m.upstreamCalls, m.upstreamDuration, m.upstreamInFlight, m.upstreamRetries,
+		m.breakerRejections, m.breakerState,
+	)
+	return m
+}
+
+// RequestStarted counts a request as in flight until RequestFinished.
+func (m *Metrics) RequestStarted() {
+	if m == nil {
+		return
+	}
+	m.requestsInFlight.Inc()
+}
+
+// RequestFinished records a request served with status.
+func (m *Metrics) RequestFinished(route, method string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requestsInFlight.Dec()
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// UpstreamStarted counts a call to form3 as in flight until UpstreamFinished.
+func (m *Metrics) UpstreamStarted() {
+	if m == nil {
+		return
+	}
+	m.upstreamInFlight.Inc()
+}
+
+// UpstreamFinished records a call to form3. status is 0 when form3 could not
+// be reached; errorKind is empty when the call succeeded.
+func (m *Metrics) UpstreamFinished(method string, status int, errorKind string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	statusLabel := ""
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	m.upstreamInFlight.Dec()
+	m.upstreamCalls.WithLabelValues(method, statusLabel, errorKind).Inc()
+	m.upstreamDuration.WithLabelValues(method, statusLabel).Observe(duration.Seconds())
+}
+
+func (m *Metrics) UpstreamRetried(method string) {
+	if m == nil {
+		return
+	}
+	m.upstreamRetries.WithLabelValues(method).Inc()
+}
+
+func (m *Metrics) CircuitBreakerRejected() {
+	if m == nil {
+		return
+	}
+	m.breakerRejections.Inc()
+}
+
+// SetCircuitBreakerState records the current state of the circuit breaker,
+// as the number of its BreakerState.
+func (m *Metrics) SetCircuitBreakerState(state int) {
+	if m == nil {
+		return
+	}
+	m.breakerState.Set(float64(state))
+}
@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"form3-interview/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_metrics(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	m := metrics.New(registry)
+
+	m.RequestStarted()
+	m.RequestStarted()
+	m.RequestFinished("/form3Client/accounts/{accountId}", "GET", 200, 10*time.Millisecond)
+	m.UpstreamStarted()
+	m.UpstreamFinished("GET", 503, "server_error", 5*time.Millisecond)
+	m.UpstreamStarted()
+	m.UpstreamFinished("GET", 0, "timeout", 5*time.Millisecond)
+	m.UpstreamRetried("GET")
+	m.CircuitBreakerRejected()
+	m.SetCircuitBreakerState(1)
+
+	expected := `
+# HELP form3_proxy_requests_in_flight Requests being served.
+# TYPE form3_proxy_requests_in_flight gauge
+form3_proxy_requests_in_flight 1
+# HELP form3_proxy_requests_total Requests served, by route, method and status.
+# TYPE form3_proxy_requests_total counter
+form3_proxy_requests_total{method="GET",route="/form3Client/accounts/{accountId}",status="200"} 1
+# HELP form3_proxy_upstream_requests_in_flight Calls to form3 waiting for a response.
+# TYPE form3_proxy_upstream_requests_in_flight gauge
+form3_proxy_upstream_requests_in_flight 0
+# HELP form3_proxy_upstream_requests_total Calls made to form3, by method, status and error kind. Status is empty when form3 could not be reached.
+# TYPE form3_proxy_upstream_requests_total counter
+form3_proxy_upstream_requests_total{error_kind="server_error",method="GET",status="503"} 1
+form3_proxy_upstream_requests_total{error_kind="timeout",method="GET",status=""} 1
+# HELP form3_proxy_upstream_retries_total Calls to form3 retried after a transient failure, by method.
+# TYPE form3_proxy_upstream_retries_total counter
+form3_proxy_upstream_retries_total{method="GET"} 1
+# HELP form3_proxy_circuit_breaker_rejections_total Calls to form3 refused because the circuit breaker was open.
+# TYPE form3_proxy_circuit_breaker_rejections_total counter
+form3_proxy_circuit_breaker_rejections_total 1
+# HELP form3_proxy_circuit_breaker_state State of the circuit breaker: 0 closed, 1 open, 2 half-open.
+# TYPE form3_proxy_circuit_breaker_state gauge
+form3_proxy_circuit_breaker_state 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"form3_proxy_requests_in_flight", "form3_proxy_requests_total",
+		"form3_proxy_upstream_requests_in_flight", "form3_proxy_upstream_requests_total",
+		"form3_proxy_upstream_retries_total", "form3_proxy_circuit_breaker_rejections_total",
+		"form3_proxy_circuit_breaker_state")
+	assert.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(registry, "form3_proxy_request_duration_seconds", "form3_proxy_upstream_request_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func Test_nilMetrics(t *testing.T) {
+	t.Parallel()
+
+	var m *metrics.Metrics
+	assert.NotPanics(t, func() {
+		m.RequestStarted()
+		m.RequestFinished("/", "GET", 200, time.Millisecond)
+		m.UpstreamStarted()
+		m.UpstreamFinished("GET", 200, "", time.Millisecond)
+		m.UpstreamRetried("GET")
+		m.CircuitBreakerRejected()
+		m.SetCircuitBreakerState(0)
+	})
+}
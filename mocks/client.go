@@ -5,6 +5,8 @@
 package mock_form3_client
 
 import (
+	context "context"
+	form3_client "form3-interview/clients"
 	models "form3-interview/models"
 	io "io"
 	http "net/http"
@@ -36,18 +38,47 @@ func (m *MockForm3ClientIface) EXPECT() *MockForm3ClientIfaceMockRecorder {
 	return m.recorder
 }
 
+// CreateAccount mocks base method.
+func (m *MockForm3ClientIface) CreateAccount(ctx context.Context, accountData models.AccountData) (models.AccountWrapper, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccount", ctx, accountData)
+	ret0, _ := ret[0].(models.AccountWrapper)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccount indicates an expected call of CreateAccount.
+func (mr *MockForm3ClientIfaceMockRecorder) CreateAccount(ctx, accountData interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).CreateAccount), ctx, accountData)
+}
+
 // DeleteAccount mocks base method.
-func (m *MockForm3ClientIface) DeleteAccount(accountId, version string) models.AppError {
+func (m *MockForm3ClientIface) DeleteAccount(ctx context.Context, accountId string, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", accountId, version)
-	ret0, _ := ret[0].(models.AppError)
+	ret := m.ctrl.Call(m, "DeleteAccount", ctx, accountId, version)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockForm3ClientIfaceMockRecorder) DeleteAccount(accountId, version interface{}) *gomock.Call {
+func (mr *MockForm3ClientIfaceMockRecorder) DeleteAccount(ctx, accountId, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).DeleteAccount), accountId, version)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).DeleteAccount), ctx, accountId, version)
+}
+
+// DeleteCurrentAccount mocks base method.
+func (m *MockForm3ClientIface) DeleteCurrentAccount(ctx context.Context, accountId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCurrentAccount", ctx, accountId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCurrentAccount indicates an expected call of DeleteCurrentAccount.
+func (mr *MockForm3ClientIfaceMockRecorder) DeleteCurrentAccount(ctx, accountId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCurrentAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).DeleteCurrentAccount), ctx, accountId)
 }
 
 // Do mocks base method.
@@ -66,31 +97,77 @@ func (mr *MockForm3ClientIfaceMockRecorder) Do(req interface{}) *gomock.Call {
 }
 
 // GetAccount mocks base method.
-func (m *MockForm3ClientIface) GetAccount(accountId string) (models.AccountWrapper, models.AppError) {
+func (m *MockForm3ClientIface) GetAccount(ctx context.Context, accountId string) (models.AccountWrapper, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", accountId)
+	ret := m.ctrl.Call(m, "GetAccount", ctx, accountId)
 	ret0, _ := ret[0].(models.AccountWrapper)
-	ret1, _ := ret[1].(models.AppError)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAccount indicates an expected call of GetAccount.
-func (mr *MockForm3ClientIfaceMockRecorder) GetAccount(accountId interface{}) *gomock.Call {
+func (mr *MockForm3ClientIfaceMockRecorder) GetAccount(ctx, accountId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).GetAccount), accountId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).GetAccount), ctx, accountId)
+}
+
+// Health mocks base method.
+func (m *MockForm3ClientIface) Health(ctx context.Context) (models.Health, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health", ctx)
+	ret0, _ := ret[0].(models.Health)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockForm3ClientIfaceMockRecorder) Health(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockForm3ClientIface)(nil).Health), ctx)
+}
+
+// ListAccounts mocks base method.
+func (m *MockForm3ClientIface) ListAccounts(ctx context.Context, options form3_client.ListOptions) ([]models.AccountData, models.Links, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccounts", ctx, options)
+	ret0, _ := ret[0].([]models.AccountData)
+	ret1, _ := ret[1].(models.Links)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListAccounts indicates an expected call of ListAccounts.
+func (mr *MockForm3ClientIfaceMockRecorder) ListAccounts(ctx, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockForm3ClientIface)(nil).ListAccounts), ctx, options)
 }
 
 // PostAccount mocks base method.
-func (m *MockForm3ClientIface) PostAccount(body io.Reader) (models.AccountWrapper, models.AppError) {
+func (m *MockForm3ClientIface) PostAccount(ctx context.Context, body io.Reader) (models.AccountWrapper, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PostAccount", body)
+	ret := m.ctrl.Call(m, "PostAccount", ctx, body)
 	ret0, _ := ret[0].(models.AccountWrapper)
-	ret1, _ := ret[1].(models.AppError)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // PostAccount indicates an expected call of PostAccount.
-func (mr *MockForm3ClientIfaceMockRecorder) PostAccount(body interface{}) *gomock.Call {
+func (mr *MockForm3ClientIfaceMockRecorder) PostAccount(ctx, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).PostAccount), ctx, body)
+}
+
+// UpdateAccount mocks base method.
+func (m *MockForm3ClientIface) UpdateAccount(ctx context.Context, accountId string, version int64, attributes models.AccountAttributes) (models.AccountWrapper, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccount", ctx, accountId, version, attributes)
+	ret0, _ := ret[0].(models.AccountWrapper)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccount indicates an expected call of UpdateAccount.
+func (mr *MockForm3ClientIfaceMockRecorder) UpdateAccount(ctx, accountId, version, attributes interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).PostAccount), body)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockForm3ClientIface)(nil).UpdateAccount), ctx, accountId, version, attributes)
 }
@@ -3,32 +3,247 @@
 // more information about fields.
 package models
 
+import "encoding/json"
+
+// The types of this file keep the fields they do not know about in Extra and
+// write them back out as they were received, so newer form3 fields survive a
+// round trip through this service, however deep they are nested.
 type AccountWrapper struct {
-	Account AccountData `json:"data"`
+	Account AccountData                `json:"data"`
+	Extra   map[string]json.RawMessage `json:"-"`
 }
 
 type AccountData struct {
-	Attributes     *AccountAttributes `json:"attributes,omitempty"`
-	ID             string             `json:"id,omitempty"`
-	OrganisationID string             `json:"organisation_id,omitempty"`
-	Type           string             `json:"type,omitempty"`
-	Version        *int64             `json:"version,omitempty"`
+	Attributes     *AccountAttributes         `json:"attributes,omitempty"`
+	ID             string                     `json:"id,omitempty"`
+	OrganisationID string                     `json:"organisation_id,omitempty"`
+	Relationships  *AccountRelationships      `json:"relationships,omitempty"`
+	Type           ResourceType               `json:"type,omitempty"`
+	Version        *int64                     `json:"version,omitempty"`
+	Extra          map[string]json.RawMessage `json:"-"`
 }
 
 type AccountAttributes struct {
-	AccountClassification   *string  `json:"account_classification,omitempty"`
-	AccountMatchingOptOut   *bool    `json:"account_matching_opt_out,omitempty"`
-	AccountNumber           string   `json:"account_number,omitempty"`
-	AlternativeNames        []string `json:"alternative_names,omitempty"`
-	BankID                  string   `json:"bank_id,omitempty"`
-	BankIDCode              string   `json:"bank_id_code,omitempty"`
-	BaseCurrency            string   `json:"base_currency,omitempty"`
-	Bic                     string   `json:"bic,omitempty"`
-	Country                 *string  `json:"country,omitempty" validate:"required,country"`
-	Iban                    string   `json:"iban,omitempty"`
-	JointAccount            *bool    `json:"joint_account,omitempty"`
-	Name                    []string `json:"name,omitempty"`
-	SecondaryIdentification string   `json:"secondary_identification,omitempty"`
-	Status                  *string  `json:"status,omitempty"`
-	Switched                *bool    `json:"switched,omitempty"`
+	AcceptanceQualifier        string                      `json:"acceptance_qualifier,omitempty"`
+	AccountClassification      *AccountClassification      `json:"account_classification,omitempty"`
+	AccountMatchingOptOut      *bool                       `json:"account_matching_opt_out,omitempty"`
+	AccountNumber              string                      `json:"account_number,omitempty"`
+	AlternativeNames           []string                    `json:"alternative_names,omitempty"`
+	BankID                     string                      `json:"bank_id,omitempty"`
+	BankIDCode                 BankIDCode                  `json:"bank_id_code,omitempty"`
+	BaseCurrency               string                      `json:"base_currency,omitempty"`
+	Bic                        string                      `json:"bic,omitempty"`
+	Country                    *string                     `json:"country,omitempty" validate:"required,country"`
+	Iban                       string                      `json:"iban,omitempty"`
+	JointAccount               *bool                       `json:"joint_account,omitempty"`
+	Name                       []string                    `json:"name,omitempty"`
+	OrganisationIdentification *OrganisationIdentification `json:"organisation_identification,omitempty"`
+	PrivateIdentification      *PrivateIdentification      `json:"private_identification,omitempty"`
+	ProcessingService          string                      `json:"processing_service,omitempty"`
+	ReferenceMask              string                      `json:"reference_mask,omitempty"`
+	SecondaryIdentification    string                      `json:"secondary_identification,omitempty"`
+	Status                     *AccountStatus              `json:"status,omitempty"`
+	Switched                   *bool                       `json:"switched,omitempty"`
+	UserDefinedData            []UserDefinedData           `json:"user_defined_data,omitempty"`
+	UserDefinedInformation     string                      `json:"user_defined_information,omitempty"`
+	ValidationType             string                      `json:"validation_type,omitempty"`
+	Extra                      map[string]json.RawMessage  `json:"-"`
+}
+
+// UserDefinedData is a free key/value pair stored with the account.
+type UserDefinedData struct {
+	Key   string                     `json:"key"`
+	Value string                     `json:"value"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// PrivateIdentification identifies the person holding a personal account.
+type PrivateIdentification struct {
+	Address        []string                   `json:"address,omitempty"`
+	BirthCountry   string                     `json:"birth_country,omitempty"`
+	BirthDate      string                     `json:"birth_date,omitempty"`
+	City           string                     `json:"city,omitempty"`
+	Country        string                     `json:"country,omitempty"`
+	DocumentNumber string                     `json:"document_number,omitempty"`
+	FirstName      string                     `json:"first_name,omitempty"`
+	Identification string                     `json:"identification,omitempty"`
+	LastName       string                     `json:"last_name,omitempty"`
+	Title          string                     `json:"title,omitempty"`
+	Extra          map[string]json.RawMessage `json:"-"`
+}
+
+// OrganisationIdentification identifies the organisation holding a business
+// account.
+type OrganisationIdentification struct {
+	Actors             []OrganisationActor        `json:"actors,omitempty"`
+	Address            []string                   `json:"address,omitempty"`
+	City               string                     `json:"city,omitempty"`
+	Country            string                     `json:"country,omitempty"`
+	Identification     string                     `json:"identification,omitempty"`
+	Name               []string                   `json:"name,omitempty"`
+	RegistrationNumber string                     `json:"registration_number,omitempty"`
+	TaxResidency       string                     `json:"tax_residency,omitempty"`
+	Extra              map[string]json.RawMessage `json:"-"`
+}
+
+// OrganisationActor is a person acting on behalf of an organisation.
+type OrganisationActor struct {
+	BirthDate string                     `json:"birth_date,omitempty"`
+	Name      []string                   `json:"name,omitempty"`
+	Residency string                     `json:"residency,omitempty"`
+	Extra     map[string]json.RawMessage `json:"-"`
+}
+
+// AccountRelationships links the account to other form3 resources.
+type AccountRelationships struct {
+	AccountEvents *RelationshipLinks         `json:"account_events,omitempty"`
+	MasterAccount *RelationshipLinks         `json:"master_account,omitempty"`
+	Extra         map[string]json.RawMessage `json:"-"`
+}
+
+type RelationshipLinks struct {
+	Data  []ResourceIdentifier       `json:"data"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ResourceIdentifier points at another form3 resource.
+type ResourceIdentifier struct {
+	ID    string                     `json:"id"`
+	Type  string                     `json:"type"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type AccountListWrapper struct {
+	Accounts []AccountData              `json:"data"`
+	Links    Links                      `json:"links"`
+	Extra    map[string]json.RawMessage `json:"-"`
+}
+
+type Links struct {
+	First string                     `json:"first,omitempty"`
+	Last  string                     `json:"last,omitempty"`
+	Next  string                     `json:"next,omitempty"`
+	Prev  string                     `json:"prev,omitempty"`
+	Self  string                     `json:"self,omitempty"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (w AccountWrapper) MarshalJSON() ([]byte, error) {
+	type plain AccountWrapper
+	return marshalWithExtra(plain(w), w.Extra)
+}
+
+func (w *AccountWrapper) UnmarshalJSON(data []byte) error {
+	type plain AccountWrapper
+	return unmarshalWithExtra(data, (*plain)(w), &w.Extra)
+}
+
+func (a AccountData) MarshalJSON() ([]byte, error) {
+	type plain AccountData
+	return marshalWithExtra(plain(a), a.Extra)
+}
+
+func (a *AccountData) UnmarshalJSON(data []byte) error {
+	type plain AccountData
+	return unmarshalWithExtra(data, (*plain)(a), &a.Extra)
+}
+
+func (a AccountAttributes) MarshalJSON() ([]byte, error) {
+	type plain AccountAttributes
+	return marshalWithExtra(plain(a), a.Extra)
+}
+
+func (a *AccountAttributes) UnmarshalJSON(data []byte) error {
+	type plain AccountAttributes
+	return unmarshalWithExtra(data, (*plain)(a), &a.Extra)
+}
+
+func (u UserDefinedData) MarshalJSON() ([]byte, error) {
+	type plain UserDefinedData
+	return marshalWithExtra(plain(u), u.Extra)
+}
+
+func (u *UserDefinedData) UnmarshalJSON(data []byte) error {
+	type plain UserDefinedData
+	return unmarshalWithExtra(data, (*plain)(u), &u.Extra)
+}
+
+func (p PrivateIdentification) MarshalJSON() ([]byte, error) {
+	type plain PrivateIdentification
+	return marshalWithExtra(plain(p), p.Extra)
+}
+
+func (p *PrivateIdentification) UnmarshalJSON(data []byte) error {
+	type plain PrivateIdentification
+	return unmarshalWithExtra(data, (*plain)(p), &p.Extra)
+}
+
+func (o OrganisationIdentification) MarshalJSON() ([]byte, error) {
+	type plain OrganisationIdentification
+	return marshalWithExtra(plain(o), o.Extra)
+}
+
+func (o *OrganisationIdentification) UnmarshalJSON(data []byte) error {
+	type plain OrganisationIdentification
+	return unmarshalWithExtra(data, (*plain)(o), &o.Extra)
+}
+
+func (o OrganisationActor) MarshalJSON() ([]byte, error) {
+	type plain OrganisationActor
+	return marshalWithExtra(plain(o), o.Extra)
+}
+
+func (o *OrganisationActor) UnmarshalJSON(data []byte) error {
+	type plain OrganisationActor
+	return unmarshalWithExtra(data, (*plain)(o), &o.Extra)
+}
+
+func (a AccountRelationships) MarshalJSON() ([]byte, error) {
+	type plain AccountRelationships
+	return marshalWithExtra(plain(a), a.Extra)
+}
+
+func (a *AccountRelationships) UnmarshalJSON(data []byte) error {
+	type plain AccountRelationships
+	return unmarshalWithExtra(data, (*plain)(a), &a.Extra)
+}
+
+func (r RelationshipLinks) MarshalJSON() ([]byte, error) {
+	type plain RelationshipLinks
+	return marshalWithExtra(plain(r), r.Extra)
+}
+
+func (r *RelationshipLinks) UnmarshalJSON(data []byte) error {
+	type plain RelationshipLinks
+	return unmarshalWithExtra(data, (*plain)(r), &r.Extra)
+}
+
+func (r ResourceIdentifier) MarshalJSON() ([]byte, error) {
+	type plain ResourceIdentifier
+	return marshalWithExtra(plain(r), r.Extra)
+}
+
+func (r *ResourceIdentifier) UnmarshalJSON(data []byte) error {
+	type plain ResourceIdentifier
+	return unmarshalWithExtra(data, (*plain)(r), &r.Extra)
+}
+
+func (a AccountListWrapper) MarshalJSON() ([]byte, error) {
+	type plain AccountListWrapper
+	return marshalWithExtra(plain(a), a.Extra)
+}
+
+func (a *AccountListWrapper) UnmarshalJSON(data []byte) error {
+	type plain AccountListWrapper
+	return unmarshalWithExtra(data, (*plain)(a), &a.Extra)
+}
+
+func (l Links) MarshalJSON() ([]byte, error) {
+	type plain Links
+	return marshalWithExtra(plain(l), l.Extra)
+}
+
+func (l *Links) UnmarshalJSON(data []byte) error {
+	type plain Links
+	return unmarshalWithExtra(data, (*plain)(l), &l.Extra)
 }
@@ -0,0 +1,163 @@
+package models
+
+// AccountBuilder assembles an account step by step, taking care of the
+// optional pointer fields. Build validates the result the way CreateAccount
+// does, so an account that builds is one form3 should accept.
+//
+//	account, err := models.GBAccount(organisationID).
+//		WithBank(models.BankIDCodeGB, "400300", "NWBKGB22").
+//		WithNames("Samantha Holder").
+//		Build()
+type AccountBuilder struct {
+	account    AccountData
+	attributes AccountAttributes
+}
+
+// NewAccountBuilder starts an account of the organisation with no attributes
+// set.
+func NewAccountBuilder(organisationID string) *AccountBuilder {
+	return &AccountBuilder{
+		account: AccountData{
+			OrganisationID: organisationID,
+			Type:           AccountType,
+		},
+	}
+}
+
+// GBAccount starts an account held in the United Kingdom, in pounds, whose
+// bank is identified by a sort code.
+func GBAccount(organisationID string) *AccountBuilder {
+	return NewAccountBuilder(organisationID).WithCountry("GB").WithBaseCurrency("GBP").WithBankIDCode(BankIDCodeGB)
+}
+
+// DEAccount starts an account held in Germany, in euros, whose bank is
+// identified by a Bankleitzahl.
+func DEAccount(organisationID string) *AccountBuilder {
+	return NewAccountBuilder(organisationID).WithCountry("DE").WithBaseCurrency("EUR").WithBankIDCode(BankIDCodeDE)
+}
+
+// FRAccount starts an account held in France, in euros, whose bank is
+// identified by its bank and branch codes.
+func FRAccount(organisationID string) *AccountBuilder {
+	return NewAccountBuilder(organisationID).WithCountry("FR").WithBaseCurrency("EUR").WithBankIDCode(BankIDCodeFR)
+}
+
+// WithID sets the id of the account; Build generates one when it is not set.
+func (b *AccountBuilder) WithID(id string) *AccountBuilder {
+	b.account.ID = id
+	return b
+}
+
+func (b *AccountBuilder) WithVersion(version int64) *AccountBuilder {
+	b.account.Version = &version
+	return b
+}
+
+func (b *AccountBuilder) WithCountry(country string) *AccountBuilder {
+	b.attributes.Country = &country
+	return b
+}
+
+func (b *AccountBuilder) WithBaseCurrency(currency string) *AccountBuilder {
+	b.attributes.BaseCurrency = currency
+	return b
+}
+
+// WithBank sets the bank holding the account: its bank id, the scheme of that
+// id and its BIC. Empty values leave the field unset.
+func (b *AccountBuilder) WithBank(bankIDCode BankIDCode, bankID, bic string) *AccountBuilder {
+	return b.WithBankIDCode(bankIDCode).WithBankID(bankID).WithBic(bic)
+}
+
+func (b *AccountBuilder) WithBankIDCode(bankIDCode BankIDCode) *AccountBuilder {
+	b.attributes.BankIDCode = bankIDCode
+	return b
+}
+
+func (b *AccountBuilder) WithBankID(bankID string) *AccountBuilder {
+	b.attributes.BankID = bankID
+	return b
+}
+
+func (b *AccountBuilder) WithBic(bic string) *AccountBuilder {
+	b.attributes.Bic = bic
+	return b
+}
+
+func (b *AccountBuilder) WithAccountNumber(accountNumber string) *AccountBuilder {
+	b.attributes.AccountNumber = accountNumber
+	return b
+}
+
+func (b *AccountBuilder) WithIban(iban string) *AccountBuilder {
+	b.attributes.Iban = iban
+	return b
+}
+
+// WithNames sets the names of the account holder, replacing any set before.
+func (b *AccountBuilder) WithNames(names ...string) *AccountBuilder {
+	b.attributes.Name = append([]string(nil), names...)
+	return b
+}
+
+// WithAlternativeNames sets the alternative names of the account holder,
+// replacing any set before.
+func (b *AccountBuilder) WithAlternativeNames(names ...string) *AccountBuilder {
+	b.attributes.AlternativeNames = append([]string(nil), names...)
+	return b
+}
+
+func (b *AccountBuilder) WithClassification(classification AccountClassification) *AccountBuilder {
+	b.attributes.AccountClassification = &classification
+	return b
+}
+
+func (b *AccountBuilder) WithSecondaryIdentification(identification string) *AccountBuilder {
+	b.attributes.SecondaryIdentification = identification
+	return b
+}
+
+// WithUserDefinedData adds a key/value pair to the account.
+func (b *AccountBuilder) WithUserDefinedData(key, value string) *AccountBuilder {
+	b.attributes.UserDefinedData = append(b.attributes.UserDefinedData, UserDefinedData{Key: key, Value: value})
+	return b
+}
+
+// Joint marks the account as held by more than one person.
+func (b *AccountBuilder) Joint() *AccountBuilder {
+	joint := true
+	b.attributes.JointAccount = &joint
+	return b
+}
+
+// MatchingOptOut opts the account out of account matching.
+func (b *AccountBuilder) MatchingOptOut() *AccountBuilder {
+	optOut := true
+	b.attributes.AccountMatchingOptOut = &optOut
+	return b
+}
+
+// Switched marks the account as switched to another bank.
+func (b *AccountBuilder) Switched() *AccountBuilder {
+	switched := true
+	b.attributes.Switched = &switched
+	return b
+}
+
+// Build returns the account wrapped for form3, or the ValidationErrors it
+// fails with. The builder can be changed and built again afterwards without
+// affecting the accounts already built.
+func (b *AccountBuilder) Build() (AccountWrapper, error) {
+	account := b.account
+	attributes := b.attributes
+	attributes.Name = append([]string(nil), b.attributes.Name...)
+	attributes.AlternativeNames = append([]string(nil), b.attributes.AlternativeNames...)
+	attributes.UserDefinedData = append([]UserDefinedData(nil), b.attributes.UserDefinedData...)
+	account.Attributes = &attributes
+
+	account.SetDefaults()
+	if err := account.Validate(); err != nil {
+		return AccountWrapper{}, err
+	}
+	return AccountWrapper{Account: account}, nil
+}
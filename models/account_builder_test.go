@@ -0,0 +1,129 @@
+package models_test
+
+import (
+	"form3-interview/models"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_accountBuilder(t *testing.T) {
+	t.Parallel()
+
+	const organisationID = "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c"
+	testCases := []struct {
+		name     string
+		builder  *models.AccountBuilder
+		expected models.ValidationErrors
+	}{
+		{
+			name: "GB preset",
+			builder: models.GBAccount(organisationID).
+				WithBank(models.BankIDCodeGB, "400300", "NWBKGB22").
+				WithNames("Samantha Holder"),
+		},
+		{
+			name: "DE preset",
+			builder: models.DEAccount(organisationID).
+				WithBankID("37040044").
+				WithIban("DE89370400440532013000").
+				WithNames("Samantha Holder"),
+		},
+		{
+			name: "FR preset",
+			builder: models.FRAccount(organisationID).
+				WithBankID("2004101005").
+				WithIban("FR1420041010050500013M02606").
+				WithNames("Samantha Holder"),
+		},
+		{
+			name:    "GB preset without bank details",
+			builder: models.GBAccount(organisationID).WithNames("Samantha Holder"),
+			expected: models.ValidationErrors{
+				{Field: "attributes.bank_id", Message: "is required for GB"},
+				{Field: "attributes.bic", Message: "is required for GB"},
+			},
+		},
+		{
+			name:    "no organisation, country or names",
+			builder: models.NewAccountBuilder(""),
+			expected: models.ValidationErrors{
+				{Field: "organisation_id", Message: "is required"},
+				{Field: "attributes.country", Message: "is required"},
+				{Field: "attributes.name", Message: "is required"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			account, err := test.builder.Build()
+			if test.expected != nil {
+				var validationErrors models.ValidationErrors
+				assert.True(t, errors.As(err, &validationErrors))
+				assert.Equal(t, test.expected, validationErrors)
+				assert.Equal(t, models.AccountWrapper{}, account)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, uuid.Parse(account.Account.ID))
+			assert.Equal(t, models.AccountType, account.Account.Type)
+			assert.Equal(t, organisationID, account.Account.OrganisationID)
+		})
+	}
+}
+
+func Test_accountBuilderFields(t *testing.T) {
+	t.Parallel()
+
+	builder := models.GBAccount("eb0bd6f5-c3f5-44b2-b677-acd23cdde73c").
+		WithID("cb1e2074-1056-4b27-b4e0-ed9f0c46b066").
+		WithVersion(2).
+		WithBank(models.BankIDCodeGB, "400300", "NWBKGB22").
+		WithAccountNumber("41426819").
+		WithNames("Samantha Holder", "Sam Holder").
+		WithAlternativeNames("Sammy").
+		WithClassification(models.Business).
+		WithSecondaryIdentification("A1B2C3D4").
+		WithUserDefinedData("key", "value").
+		Joint().
+		MatchingOptOut().
+		Switched()
+
+	account, err := builder.Build()
+	assert.NoError(t, err)
+
+	joint := true
+	expected := models.AccountWrapper{
+		Account: models.AccountData{
+			ID:             "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+			OrganisationID: "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c",
+			Type:           models.AccountType,
+			Version:        func() *int64 { version := int64(2); return &version }(),
+			Attributes: &models.AccountAttributes{
+				AccountClassification:   models.Business.Ptr(),
+				AccountMatchingOptOut:   &joint,
+				AccountNumber:           "41426819",
+				AlternativeNames:        []string{"Sammy"},
+				BankID:                  "400300",
+				BankIDCode:              models.BankIDCodeGB,
+				BaseCurrency:            "GBP",
+				Bic:                     "NWBKGB22",
+				Country:                 stringPtr("GB"),
+				JointAccount:            &joint,
+				Name:                    []string{"Samantha Holder", "Sam Holder"},
+				SecondaryIdentification: "A1B2C3D4",
+				Switched:                &joint,
+				UserDefinedData:         []models.UserDefinedData{{Key: "key", Value: "value"}},
+			},
+		},
+	}
+	assert.Equal(t, expected, account)
+
+	builder.WithNames("Someone Else").WithUserDefinedData("other", "value")
+	assert.Equal(t, []string{"Samantha Holder", "Sam Holder"}, account.Account.Attributes.Name)
+	assert.Len(t, account.Account.Attributes.UserDefinedData, 1)
+}
@@ -0,0 +1,119 @@
+package models_test
+
+import (
+	"encoding/json"
+	"form3-interview/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const fullAccount = `{
+	"data": {
+		"attributes": {
+			"acceptance_qualifier": "same_day",
+			"account_classification": "Business",
+			"account_number": "41426819",
+			"bank_id": "400300",
+			"bank_id_code": "GBDSC",
+			"base_currency": "GBP",
+			"bic": "NWBKGB22",
+			"country": "GB",
+			"iban": "GB11NWBK40030041426819",
+			"name": ["Acme Ltd"],
+			"name_matching_status": "supported",
+			"organisation_identification": {
+				"actors": [{"birth_date": "1970-01-01", "name": ["Jo Bloggs"], "nationality": "GB", "residency": "GB"}],
+				"address": ["10 Acme Street"],
+				"city": "London",
+				"country": "GB",
+				"identification": "123654",
+				"legal_form": "Ltd",
+				"registration_number": "10000000"
+			},
+			"private_identification": {
+				"birth_date": "2017-07-23",
+				"birth_country": "GB",
+				"identification": "13YH458762",
+				"address": ["10 Avenue des Champs"],
+				"city": "London",
+				"country": "GB",
+				"new_field": {"kept": true}
+			},
+			"processing_service": "ABC Bank",
+			"reference_mask": "############",
+			"user_defined_data": [{"key": "Some account related key", "value": "Some account related value", "scope": "account"}],
+			"user_defined_information": "Some important info",
+			"validation_type": "card"
+		},
+		"created_on": "2020-01-01T00:00:00.000Z",
+		"id": "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+		"organisation_id": "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c",
+		"relationships": {
+			"account_events": {"data": [{"id": "c1023677-70ee-417a-9a6a-e211241f1e9c", "type": "account_events", "version": 2}], "meta": {"count": 1}},
+			"customer": {"data": [{"id": "9f2a7f4c-1b0e-4c8e-9d43-3f1d5f0a6b11", "type": "customers"}]},
+			"master_account": {"data": [{"id": "a52d13a4-f435-4c00-cfad-f5e7ac5972df", "type": "accounts"}]}
+		},
+		"type": "accounts",
+		"version": 0
+	},
+	"links": {"self": "/v1/organisation/accounts/cb1e2074-1056-4b27-b4e0-ed9f0c46b066"}
+}`
+
+func Test_accountRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	var account models.AccountWrapper
+	assert.NoError(t, json.Unmarshal([]byte(fullAccount), &account))
+
+	attributes := account.Account.Attributes
+	assert.Equal(t, "card", attributes.ValidationType)
+	assert.Equal(t, "Some account related key", attributes.UserDefinedData[0].Key)
+	assert.JSONEq(t, `"account"`, string(attributes.UserDefinedData[0].Extra["scope"]))
+	assert.JSONEq(t, `{"kept":true}`, string(attributes.PrivateIdentification.Extra["new_field"]))
+	assert.JSONEq(t, `"Ltd"`, string(attributes.OrganisationIdentification.Extra["legal_form"]))
+	assert.JSONEq(t, `"GB"`, string(attributes.OrganisationIdentification.Actors[0].Extra["nationality"]))
+	assert.Contains(t, account.Account.Relationships.Extra, "customer")
+	assert.Contains(t, account.Account.Relationships.AccountEvents.Extra, "meta")
+	assert.JSONEq(t, `2`, string(account.Account.Relationships.AccountEvents.Data[0].Extra["version"]))
+	assert.Equal(t, "13YH458762", attributes.PrivateIdentification.Identification)
+	assert.Equal(t, "10000000", attributes.OrganisationIdentification.RegistrationNumber)
+	assert.Equal(t, "a52d13a4-f435-4c00-cfad-f5e7ac5972df", account.Account.Relationships.MasterAccount.Data[0].ID)
+	assert.JSONEq(t, `"supported"`, string(attributes.Extra["name_matching_status"]))
+	assert.JSONEq(t, `"2020-01-01T00:00:00.000Z"`, string(account.Account.Extra["created_on"]))
+	assert.Contains(t, account.Extra, "links")
+
+	body, err := json.Marshal(account)
+	assert.NoError(t, err)
+	assert.JSONEq(t, fullAccount, string(body))
+
+	data, err := json.Marshal(account.Account)
+	assert.NoError(t, err)
+	list := `{"data":[` + string(data) + `],"links":{"self":"/v1/organisation/accounts","total":1},"meta":{"page":0}}`
+	var accounts models.AccountListWrapper
+	assert.NoError(t, json.Unmarshal([]byte(list), &accounts))
+	assert.Contains(t, accounts.Extra, "meta")
+	assert.Contains(t, accounts.Links.Extra, "total")
+	body, err = json.Marshal(accounts)
+	assert.NoError(t, err)
+	assert.JSONEq(t, list, string(body))
+}
+
+func Test_accountExtraDoesNotOverrideFields(t *testing.T) {
+	t.Parallel()
+
+	account := models.AccountData{
+		ID: "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+		Extra: map[string]json.RawMessage{
+			"id":        json.RawMessage(`"overridden"`),
+			"Type":      json.RawMessage(`"overridden"`),
+			"new_field": json.RawMessage(`{"kept":true}`),
+		},
+	}
+	body, err := json.Marshal(account)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"cb1e2074-1056-4b27-b4e0-ed9f0c46b066","new_field":{"kept":true}}`, string(body))
+
+	var decoded models.AccountData
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"1234"}`), &decoded))
+	assert.Nil(t, decoded.Extra)
+}
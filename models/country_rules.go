@@ -0,0 +1,164 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// CountryRule describes how form3 expects the bank details of an account held
+// in a given country to look.
+// See https://api-docs.form3.tech/api.html#organisation-accounts-create for
+// the rules of every supported country.
+type CountryRule struct {
+	// BankIDCode is the bank_id_code every account of the country must carry;
+	// empty when the country has no bank id.
+	BankIDCode BankIDCode
+	// BankID is the format of bank_id; nil when the country has no bank id.
+	BankID         *Format
+	BankIDRequired bool
+	BicRequired    bool
+	// AccountNumber is the format of account_number.
+	AccountNumber *Format
+	// IbanLength is the length of an IBAN of the country; 0 when the country
+	// does not use IBANs.
+	IbanLength int
+	// IbanBankID and IbanAccountNumber locate bank_id and account_number inside
+	// the IBAN, when the IBAN layout of the country carries them verbatim.
+	IbanBankID        *ibanSpan
+	IbanAccountNumber *ibanSpan
+}
+
+// Format is a pattern a field must match, with its description for error
+// messages.
+type Format struct {
+	Pattern     *regexp.Regexp
+	Description string
+}
+
+func (f *Format) match(value string) bool {
+	return f.Pattern.MatchString(value)
+}
+
+type ibanSpan struct {
+	start, end int
+}
+
+// CountryRules holds the rules of every country with specific bank details
+// requirements, keyed by ISO 3166-1 alpha-2 code. Accounts of other countries
+// only get the generic IBAN checks.
+var CountryRules = map[string]CountryRule{
+	"AU": {BankIDCode: "AUBSB", BankID: digits(6, 6), BicRequired: true, AccountNumber: digits(6, 10)},
+	"BE": {BankIDCode: "BE", BankID: digits(3, 3), BankIDRequired: true, AccountNumber: digits(7, 7), IbanLength: 16, IbanBankID: &ibanSpan{4, 7}},
+	"CA": {BankIDCode: "CACPA", BankID: &Format{regexp.MustCompile(`^0\d{8}$`), "9 digits starting with 0"}, BicRequired: true, AccountNumber: digits(7, 12)},
+	"CH": {BankIDCode: "CHBCC", BankID: digits(5, 5), BankIDRequired: true, AccountNumber: digits(12, 12), IbanLength: 21, IbanBankID: &ibanSpan{4, 9}},
+	"DE": {BankIDCode: "DEBLZ", BankID: digits(8, 8), BankIDRequired: true, AccountNumber: digits(7, 7), IbanLength: 22, IbanBankID: &ibanSpan{4, 12}},
+	"ES": {BankIDCode: "ESNCC", BankID: digits(8, 8), BankIDRequired: true, AccountNumber: digits(10, 10), IbanLength: 24, IbanBankID: &ibanSpan{4, 12}},
+	"FR": {BankIDCode: "FR", BankID: alphanumeric(10, 10), BankIDRequired: true, AccountNumber: alphanumeric(10, 10), IbanLength: 27, IbanBankID: &ibanSpan{4, 14}},
+	"GB": {BankIDCode: "GBDSC", BankID: digits(6, 6), BankIDRequired: true, BicRequired: true, AccountNumber: digits(8, 8), IbanLength: 22, IbanBankID: &ibanSpan{8, 14}, IbanAccountNumber: &ibanSpan{14, 22}},
+	"GR": {BankIDCode: "GRBIC", BankID: digits(7, 7), BankIDRequired: true, AccountNumber: digits(16, 16), IbanLength: 27, IbanBankID: &ibanSpan{4, 11}},
+	"HK": {BankIDCode: "HKNCC", BankID: digits(3, 3), BicRequired: true, AccountNumber: digits(9, 12)},
+	"IT": {BankIDCode: "ITNCC", BankID: alphanumeric(10, 11), BankIDRequired: true, AccountNumber: alphanumeric(12, 12), IbanLength: 27},
+	"LU": {BankIDCode: "LULUX", BankID: digits(3, 3), BankIDRequired: true, AccountNumber: alphanumeric(13, 13), IbanLength: 20, IbanBankID: &ibanSpan{4, 7}},
+	"NL": {BicRequired: true, AccountNumber: digits(10, 10), IbanLength: 18},
+	"PL": {BankIDCode: "PLKNR", BankID: digits(8, 8), BankIDRequired: true, AccountNumber: digits(16, 16), IbanLength: 28, IbanBankID: &ibanSpan{4, 12}},
+	"PT": {BankIDCode: "PTNCC", BankID: digits(8, 8), BankIDRequired: true, AccountNumber: digits(11, 11), IbanLength: 25, IbanBankID: &ibanSpan{4, 12}},
+	"US": {BankIDCode: "USABA", BankID: digits(9, 9), BankIDRequired: true, BicRequired: true, AccountNumber: digits(6, 17)},
+}
+
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{1,30}$`)
+
+func digits(min, max int) *Format {
+	return &Format{
+		Pattern:     regexp.MustCompile(`^\d{` + strconv.Itoa(min) + `,` + strconv.Itoa(max) + `}$`),
+		Description: lengthDescription(min, max) + " digits",
+	}
+}
+
+func alphanumeric(min, max int) *Format {
+	return &Format{
+		Pattern:     regexp.MustCompile(`^[0-9A-Z]{` + strconv.Itoa(min) + `,` + strconv.Itoa(max) + `}$`),
+		Description: lengthDescription(min, max) + " upper case letters or digits",
+	}
+}
+
+func lengthDescription(min, max int) string {
+	if min == max {
+		return strconv.Itoa(min)
+	}
+	return strconv.Itoa(min) + " to " + strconv.Itoa(max)
+}
+
+// validateCountryRules checks that the bank details are consistent with each
+// other and with the rules of country.
+func (a AccountAttributes) validateCountryRules(errs *ValidationErrors, country string) {
+	rule, known := CountryRules[country]
+
+	if known {
+		switch {
+		case rule.BankID == nil && len(a.BankID) != 0:
+			errs.add("attributes.bank_id", "is not supported for "+country)
+		case rule.BankID != nil && len(a.BankID) == 0 && rule.BankIDRequired:
+			errs.add("attributes.bank_id", "is required for "+country)
+		case rule.BankID != nil && len(a.BankID) != 0 && !rule.BankID.match(a.BankID):
+			errs.add("attributes.bank_id", "must be "+rule.BankID.Description+" for "+country)
+		}
+		switch {
+		case len(rule.BankIDCode) == 0 && len(a.BankIDCode) != 0:
+			errs.add("attributes.bank_id_code", "is not supported for "+country)
+		case len(rule.BankIDCode) != 0 && len(a.BankIDCode) == 0:
+			errs.add("attributes.bank_id_code", "is required for "+country)
+		case len(a.BankIDCode) != 0 && a.BankIDCode != rule.BankIDCode:
+			errs.add("attributes.bank_id_code", "must be "+string(rule.BankIDCode)+" for "+country)
+		}
+		if rule.BicRequired && len(a.Bic) == 0 {
+			errs.add("attributes.bic", "is required for "+country)
+		}
+		if len(a.AccountNumber) != 0 && rule.AccountNumber != nil && !rule.AccountNumber.match(a.AccountNumber) {
+			errs.add("attributes.account_number", "must be "+rule.AccountNumber.Description+" for "+country)
+		}
+	}
+
+	if bicPattern.MatchString(a.Bic) && a.Bic[4:6] != country {
+		errs.add("attributes.bic", "must belong to a bank in "+country)
+	}
+
+	if len(a.Iban) == 0 {
+		return
+	}
+	switch {
+	case known && rule.IbanLength == 0:
+		errs.add("attributes.iban", "is not supported for "+country)
+	case !ibanPattern.MatchString(a.Iban):
+		errs.add("attributes.iban", "must be upper case letters and digits only")
+	case a.Iban[:2] != country:
+		errs.add("attributes.iban", "must start with the country code "+country)
+	case known && len(a.Iban) != rule.IbanLength:
+		errs.add("attributes.iban", "must be "+strconv.Itoa(rule.IbanLength)+" characters for "+country)
+	case !validIbanChecksum(a.Iban):
+		errs.add("attributes.iban", "has an invalid check digit")
+	default:
+		if rule.IbanBankID != nil && len(a.BankID) != 0 && a.Iban[rule.IbanBankID.start:rule.IbanBankID.end] != a.BankID {
+			errs.add("attributes.iban", "does not match bank_id")
+		}
+		if rule.IbanAccountNumber != nil && len(a.AccountNumber) != 0 && a.Iban[rule.IbanAccountNumber.start:rule.IbanAccountNumber.end] != a.AccountNumber {
+			errs.add("attributes.iban", "does not match account_number")
+		}
+	}
+}
+
+// validIbanChecksum runs the ISO 13616 mod-97 check: with the first four
+// characters moved to the end and letters expanded to 10..35, the IBAN read
+// as a number must leave a remainder of 1.
+func validIbanChecksum(iban string) bool {
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, char := range rearranged {
+		if char >= 'A' && char <= 'Z' {
+			value := int(char-'A') + 10
+			remainder = (remainder*100 + value) % 97
+		} else {
+			remainder = (remainder*10 + int(char-'0')) % 97
+		}
+	}
+	return remainder == 1
+}
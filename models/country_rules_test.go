@@ -0,0 +1,173 @@
+package models_test
+
+import (
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_accountCountryRules(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		attributes models.AccountAttributes
+		expected   models.ValidationErrors
+	}{
+		{
+			name: "GB account with matching IBAN",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"), BankID: "601613", BankIDCode: "GBDSC", Bic: "NWBKGB22",
+				AccountNumber: "31926819", Iban: "GB29NWBK60161331926819",
+			},
+		},
+		{
+			name: "GB account missing bank details",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"),
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.bank_id", Message: "is required for GB"},
+				{Field: "attributes.bank_id_code", Message: "is required for GB"},
+				{Field: "attributes.bic", Message: "is required for GB"},
+			},
+		},
+		{
+			name: "GB account with malformed sort code, account number and wrong bank id code",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"), BankID: "40030", BankIDCode: "DEBLZ", Bic: "NWBKGB22", AccountNumber: "1234",
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.bank_id", Message: "must be 6 digits for GB"},
+				{Field: "attributes.bank_id_code", Message: "must be GBDSC for GB"},
+				{Field: "attributes.account_number", Message: "must be 8 digits for GB"},
+			},
+		},
+		{
+			name: "GB IBAN disagreeing with sort code and account number",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"), BankID: "400300", BankIDCode: "GBDSC", Bic: "NWBKGB22",
+				AccountNumber: "12345678", Iban: "GB29NWBK60161331926819",
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.iban", Message: "does not match bank_id"},
+				{Field: "attributes.iban", Message: "does not match account_number"},
+			},
+		},
+		{
+			name: "IBAN with a bad check digit",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"), BankID: "601613", BankIDCode: "GBDSC", Bic: "NWBKGB22", Iban: "GB28NWBK60161331926819",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.iban", Message: "has an invalid check digit"}},
+		},
+		{
+			name: "IBAN of another country and BIC of another country",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("GB"), BankID: "601613", BankIDCode: "GBDSC", Bic: "DEUTDEFF", Iban: "DE89370400440532013000",
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.bic", Message: "must belong to a bank in GB"},
+				{Field: "attributes.iban", Message: "must start with the country code GB"},
+			},
+		},
+		{
+			name: "DE account",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("DE"), BankID: "37040044", BankIDCode: "DEBLZ", Iban: "DE89370400440532013000",
+			},
+		},
+		{
+			name: "DE IBAN of the wrong length",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("DE"), BankID: "37040044", BankIDCode: "DEBLZ", Iban: "DE8937040044053201300",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.iban", Message: "must be 22 characters for DE"}},
+		},
+		{
+			name: "FR account",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("FR"), BankID: "2004101005", BankIDCode: "FR", Iban: "FR1420041010050500013M02606",
+			},
+		},
+		{
+			name: "ES bank id disagreeing with the IBAN",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("ES"), BankID: "21000419", BankIDCode: "ESNCC", Iban: "ES9121000418450200051332",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.iban", Message: "does not match bank_id"}},
+		},
+		{
+			name: "NL account has no bank id",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("NL"), BankID: "1234", BankIDCode: "GBDSC", Bic: "ABNANL2A", Iban: "NL91ABNA0417164300",
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.bank_id", Message: "is not supported for NL"},
+				{Field: "attributes.bank_id_code", Message: "is not supported for NL"},
+			},
+		},
+		{
+			name: "US account has no IBAN",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("US"), BankID: "021000021", BankIDCode: "USABA", Bic: "CHASUS33", Iban: "US12345",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.iban", Message: "is not supported for US"}},
+		},
+		{
+			name: "CA bank id must start with 0",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("CA"), BankID: "123456789", BankIDCode: "CACPA", Bic: "ROYCCAT2",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.bank_id", Message: "must be 9 digits starting with 0 for CA"}},
+		},
+		{
+			name: "AU account missing bank id code",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("AU"), Bic: "NATAAU33",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.bank_id_code", Message: "is required for AU"}},
+		},
+		{
+			name: "CA account missing bank id code",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("CA"), Bic: "ROYCCAT2",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.bank_id_code", Message: "is required for CA"}},
+		},
+		{
+			name: "HK account missing bank id code",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("HK"), Bic: "HSBCHKHH",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.bank_id_code", Message: "is required for HK"}},
+		},
+		{
+			name: "country without specific rules still gets IBAN checks",
+			attributes: models.AccountAttributes{
+				Country: stringPtr("AT"), Iban: "AT611904300234573202",
+			},
+			expected: models.ValidationErrors{{Field: "attributes.iban", Message: "has an invalid check digit"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			account := validAccount()
+			test.attributes.Name = []string{"Samantha Holder"}
+			account.Attributes = &test.attributes
+
+			err := account.Validate()
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErrors models.ValidationErrors
+			assert.True(t, errors.As(err, &validationErrors))
+			assert.Equal(t, test.expected, validationErrors)
+		})
+	}
+}
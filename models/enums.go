@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// AccountClassification is the account_classification of an account.
+//
+// The enums of this file decode from and encode to any string, so that a value
+// form3 introduces is never lost on the way through. Accounts sent by callers
+// are decoded with DecodeAccount instead, which rejects values not known here.
+type AccountClassification string
+
+const (
+	Personal AccountClassification = "Personal"
+	Business AccountClassification = "Business"
+)
+
+// AccountStatus is the status of an account.
+type AccountStatus string
+
+const (
+	StatusPending   AccountStatus = "pending"
+	StatusConfirmed AccountStatus = "confirmed"
+	StatusFailed    AccountStatus = "failed"
+)
+
+// BankIDCode identifies the scheme of bank_id, see CountryRules for the code
+// each country uses.
+type BankIDCode string
+
+const (
+	BankIDCodeAU BankIDCode = "AUBSB"
+	BankIDCodeBE BankIDCode = "BE"
+	BankIDCodeCA BankIDCode = "CACPA"
+	BankIDCodeCH BankIDCode = "CHBCC"
+	BankIDCodeDE BankIDCode = "DEBLZ"
+	BankIDCodeES BankIDCode = "ESNCC"
+	BankIDCodeFR BankIDCode = "FR"
+	BankIDCodeGB BankIDCode = "GBDSC"
+	BankIDCodeGR BankIDCode = "GRBIC"
+	BankIDCodeHK BankIDCode = "HKNCC"
+	BankIDCodeIT BankIDCode = "ITNCC"
+	BankIDCodeLU BankIDCode = "LULUX"
+	BankIDCodePL BankIDCode = "PLKNR"
+	BankIDCodePT BankIDCode = "PTNCC"
+	BankIDCodeUS BankIDCode = "USABA"
+)
+
+// ResourceType is the type of a form3 resource.
+type ResourceType string
+
+const AccountType ResourceType = "accounts"
+
+var (
+	accountClassifications = []string{string(Personal), string(Business)}
+	accountStatuses        = []string{string(StatusPending), string(StatusConfirmed), string(StatusFailed)}
+	bankIDCodes            = []string{
+		string(BankIDCodeAU), string(BankIDCodeBE), string(BankIDCodeCA), string(BankIDCodeCH), string(BankIDCodeDE),
+		string(BankIDCodeES), string(BankIDCodeFR), string(BankIDCodeGB), string(BankIDCodeGR), string(BankIDCodeHK),
+		string(BankIDCodeIT), string(BankIDCodeLU), string(BankIDCodePL), string(BankIDCodePT), string(BankIDCodeUS),
+	}
+	resourceTypes = []string{string(AccountType)}
+)
+
+// InvalidEnumError reports a value that is not one of the values allowed for
+// a field. Field is the JSON path of the field, as in FieldError.
+type InvalidEnumError struct {
+	Field   string
+	Value   string
+	Allowed []string
+}
+
+func (e *InvalidEnumError) Error() string {
+	return "invalid " + e.Field + " " + strconv.Quote(e.Value) + ", " + e.message()
+}
+
+func (e *InvalidEnumError) message() string {
+	return "must be one of " + strings.Join(e.Allowed, ", ")
+}
+
+// FieldError describes e the way Validate does.
+func (e *InvalidEnumError) FieldError() FieldError {
+	return FieldError{Field: e.Field, Message: e.message()}
+}
+
+func parseEnum(field, value string, allowed []string) error {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return nil
+		}
+	}
+	return &InvalidEnumError{Field: field, Value: value, Allowed: allowed}
+}
+
+// ParseAccountClassification returns value as an AccountClassification, or an
+// *InvalidEnumError when it is not one.
+func ParseAccountClassification(value string) (AccountClassification, error) {
+	return AccountClassification(value), parseEnum("attributes.account_classification", value, accountClassifications)
+}
+
+// Ptr returns a pointer to a copy of c, for the optional attribute.
+func (c AccountClassification) Ptr() *AccountClassification {
+	return &c
+}
+
+func (c AccountClassification) Valid() bool {
+	return parseEnum("attributes.account_classification", string(c), accountClassifications) == nil
+}
+
+// ParseAccountStatus returns value as an AccountStatus, or an
+// *InvalidEnumError when it is not one.
+func ParseAccountStatus(value string) (AccountStatus, error) {
+	return AccountStatus(value), parseEnum("attributes.status", value, accountStatuses)
+}
+
+// Ptr returns a pointer to a copy of s, for the optional attribute.
+func (s AccountStatus) Ptr() *AccountStatus {
+	return &s
+}
+
+func (s AccountStatus) Valid() bool {
+	return parseEnum("attributes.status", string(s), accountStatuses) == nil
+}
+
+// ParseBankIDCode returns value as a BankIDCode, or an *InvalidEnumError when
+// it is not one.
+func ParseBankIDCode(value string) (BankIDCode, error) {
+	return BankIDCode(value), parseEnum("attributes.bank_id_code", value, bankIDCodes)
+}
+
+func (b BankIDCode) Valid() bool {
+	return parseEnum("attributes.bank_id_code", string(b), bankIDCodes) == nil
+}
+
+// ParseResourceType returns value as a ResourceType, or an *InvalidEnumError
+// when it is not one.
+func ParseResourceType(value string) (ResourceType, error) {
+	return ResourceType(value), parseEnum("type", value, resourceTypes)
+}
+
+func (t ResourceType) Valid() bool {
+	return parseEnum("type", string(t), resourceTypes) == nil
+}
+
+// DecodeAccount decodes an account sent by a caller of the service. Its enums
+// must hold values known here: the first that does not is reported as an
+// *InvalidEnumError.
+func DecodeAccount(r io.Reader) (account AccountWrapper, err error) {
+	if err = json.NewDecoder(r).Decode(&account); err != nil {
+		return account, err
+	}
+	return account, account.Account.checkEnums()
+}
+
+func (a AccountData) checkEnums() error {
+	if len(a.Type) != 0 {
+		if _, err := ParseResourceType(string(a.Type)); err != nil {
+			return err
+		}
+	}
+	if a.Attributes == nil {
+		return nil
+	}
+	if a.Attributes.AccountClassification != nil {
+		if _, err := ParseAccountClassification(string(*a.Attributes.AccountClassification)); err != nil {
+			return err
+		}
+	}
+	if a.Attributes.Status != nil {
+		if _, err := ParseAccountStatus(string(*a.Attributes.Status)); err != nil {
+			return err
+		}
+	}
+	if len(a.Attributes.BankIDCode) != 0 {
+		if _, err := ParseBankIDCode(string(a.Attributes.BankIDCode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package models_test
+
+import (
+	"encoding/json"
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_enumsJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected models.AccountAttributes
+		err      string
+	}{
+		{
+			name: "known values",
+			body: `{"account_classification":"Business","status":"confirmed","bank_id_code":"DEBLZ"}`,
+			expected: models.AccountAttributes{
+				AccountClassification: models.Business.Ptr(),
+				Status:                models.StatusConfirmed.Ptr(),
+				BankIDCode:            models.BankIDCodeDE,
+			},
+		},
+		{
+			name:     "empty bank id code",
+			body:     `{"bank_id_code":""}`,
+			expected: models.AccountAttributes{},
+		},
+		{
+			name: "values unknown here are kept",
+			body: `{"account_classification":"personal","status":"closed","bank_id_code":"SESBA"}`,
+			expected: models.AccountAttributes{
+				AccountClassification: models.AccountClassification("personal").Ptr(),
+				Status:                models.AccountStatus("closed").Ptr(),
+				BankIDCode:            models.BankIDCode("SESBA"),
+			},
+		},
+		{
+			name: "bank id code is not a string",
+			body: `{"bank_id_code":400300}`,
+			err:  "json: cannot unmarshal number into",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var attributes models.AccountAttributes
+			err := json.Unmarshal([]byte(test.body), &attributes)
+			if len(test.err) != 0 {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, attributes)
+		})
+	}
+}
+
+func Test_enumsKeepUnknownValues(t *testing.T) {
+	t.Parallel()
+
+	body, err := json.Marshal(models.AccountData{Type: "account"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"account"}`, string(body))
+	assert.False(t, models.ResourceType("account").Valid())
+
+	_, err = models.ParseBankIDCode("SESBA")
+	var enumError *models.InvalidEnumError
+	assert.True(t, errors.As(err, &enumError))
+	assert.Equal(t, "SESBA", enumError.Value)
+}
+
+func Test_decodeAccountRejectsUnknownEnumValues(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{
+			name: "known values",
+			body: `{"data":{"type":"accounts","attributes":{"account_classification":"Business","status":"confirmed","bank_id_code":"DEBLZ"}}}`,
+		},
+		{
+			name: "lower case classification",
+			body: `{"data":{"attributes":{"account_classification":"personal"}}}`,
+			err:  `invalid attributes.account_classification "personal", must be one of Personal, Business`,
+		},
+		{
+			name: "unknown status",
+			body: `{"data":{"attributes":{"status":"closed"}}}`,
+			err:  `invalid attributes.status "closed", must be one of pending, confirmed, failed`,
+		},
+		{
+			name: "unknown bank id code",
+			body: `{"data":{"attributes":{"bank_id_code":"SESBA"}}}`,
+			err:  `invalid attributes.bank_id_code "SESBA", must be one of`,
+		},
+		{
+			name: "unknown type",
+			body: `{"data":{"type":"account"}}`,
+			err:  `invalid type "account", must be one of accounts`,
+		},
+		{
+			name: "malformed json",
+			body: `{"data":`,
+			err:  "unexpected EOF",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := models.DecodeAccount(strings.NewReader(test.body))
+			if len(test.err) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
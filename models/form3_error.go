@@ -1,15 +1,168 @@
 package models
 
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// ErrCircuitOpen is wrapped by the AppError returned while the client's
+// circuit breaker refuses to call form3.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// AppError is the error returned by every form3 client call. Code holds the
+// HTTP status to report, Form3Error the decoded form3 error envelope when the
+// failure came from the API and RequestID the id form3 assigned to the call.
+// RetryAfter, when set, is how long the caller should wait before trying again.
 type AppError struct {
-	Error   error
-	Message string
-	Code    int
+	Err        error
+	Message    string
+	Code       int
+	Form3Error *Form3Error
+	RequestID  string
+	RetryAfter time.Duration
 }
 
-func NewAppError(err error, message string, code int) AppError {
-	return AppError{
-		Error:   err,
+// Form3Error is the error envelope returned by the form3 API. Most endpoints
+// answer with error_message/error_code, while routing failures use the
+// shorter code/message pair; ParseForm3Error folds both into the same fields.
+type Form3Error struct {
+	ErrorMessage string `json:"error_message,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+}
+
+func NewAppError(err error, message string, code int) *AppError {
+	return &AppError{
+		Err:     err,
 		Message: message,
 		Code:    code,
 	}
 }
+
+func (e *AppError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the form3 error code, if form3 sent one.
+func (e *AppError) ErrorCode() string {
+	if e.Form3Error == nil {
+		return ""
+	}
+	return e.Form3Error.ErrorCode
+}
+
+// ParseForm3Error decodes a form3 error response body. It returns nil when the
+// body is not a form3 error envelope.
+func ParseForm3Error(body []byte) *Form3Error {
+	var envelope struct {
+		Form3Error
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil
+	}
+	form3Error := envelope.Form3Error
+	if len(form3Error.ErrorMessage) == 0 {
+		form3Error.ErrorMessage = envelope.Message
+	}
+	if len(form3Error.ErrorCode) == 0 {
+		form3Error.ErrorCode = envelope.Code
+	}
+	if len(form3Error.ErrorMessage) == 0 && len(form3Error.ErrorCode) == 0 {
+		return nil
+	}
+	return &form3Error
+}
+
+// StatusCode returns the HTTP status carried by err, or 500 when err is not
+// an AppError.
+func StatusCode(err error) int {
+	var appError *AppError
+	if errors.As(err, &appError) && appError.Code != 0 {
+		return appError.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// IsNotFound reports whether the account or page does not exist.
+func IsNotFound(err error) bool {
+	return hasCode(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether the request clashed with the current state of
+// the account, e.g. a duplicate id or a stale version.
+func IsConflict(err error) bool {
+	return hasCode(err, http.StatusConflict)
+}
+
+// IsValidation reports whether form3 rejected the request data.
+func IsValidation(err error) bool {
+	return hasCode(err, http.StatusBadRequest) || hasCode(err, http.StatusUnprocessableEntity)
+}
+
+// IsServerError reports whether the failure happened on the server side,
+// either in form3 or while reaching it.
+func IsServerError(err error) bool {
+	return err != nil && StatusCode(err) >= http.StatusInternalServerError
+}
+
+// IsCircuitOpen reports whether the call was refused without reaching form3
+// because the circuit breaker is open.
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+func hasCode(err error, code int) bool {
+	var appError *AppError
+	return errors.As(err, &appError) && appError.Code == code
+}
+
+// ErrorKind classifies err for logs and metrics: "circuit_open", "timeout",
+// "canceled" or the StatusKind of the status it carries. It returns "" for a
+// nil err.
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case IsCircuitOpen(err):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return StatusKind(StatusCode(err))
+}
+
+// StatusKind classifies an HTTP status: "validation", "not_found", "conflict",
+// "rate_limited", "timeout", "client_error" or "server_error". It returns ""
+// for a status that is not an error.
+func StatusKind(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return ""
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return "validation"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusConflict:
+		return "conflict"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status < http.StatusInternalServerError:
+		return "client_error"
+	}
+	return "server_error"
+}
@@ -0,0 +1,40 @@
+package models_test
+
+import (
+	"context"
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_errorKind(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "no error", err: nil, expected: ""},
+		{name: "circuit open", err: models.NewAppError(models.ErrCircuitOpen, "Circuit breaker is open", http.StatusServiceUnavailable), expected: "circuit_open"},
+		{name: "deadline", err: models.NewAppError(context.DeadlineExceeded, "Timed out waiting for form3 server", http.StatusGatewayTimeout), expected: "timeout"},
+		{name: "canceled", err: models.NewAppError(context.Canceled, "Unable to reach form3 server", http.StatusInternalServerError), expected: "canceled"},
+		{name: "bad request", err: models.NewAppError(errors.New("invalid"), "Validation error", http.StatusBadRequest), expected: "validation"},
+		{name: "not found", err: models.NewAppError(errors.New("missing"), "Validation error", http.StatusNotFound), expected: "not_found"},
+		{name: "conflict", err: models.NewAppError(errors.New("stale"), "Validation error", http.StatusConflict), expected: "conflict"},
+		{name: "rate limited", err: models.NewAppError(errors.New("slow down"), "Validation error", http.StatusTooManyRequests), expected: "rate_limited"},
+		{name: "forbidden", err: models.NewAppError(errors.New("forbidden"), "Validation error", http.StatusForbidden), expected: "client_error"},
+		{name: "bad gateway", err: models.NewAppError(errors.New("down"), "Validation error", http.StatusBadGateway), expected: "server_error"},
+		{name: "not an AppError", err: errors.New("boom"), expected: "server_error"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, models.ErrorKind(test.err))
+		})
+	}
+}
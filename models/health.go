@@ -0,0 +1,9 @@
+package models
+
+// HealthUp is the status form3 reports while the account API is healthy.
+const HealthUp = "up"
+
+// Health is the body of the form3 health endpoint.
+type Health struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var knownFields sync.Map
+
+// marshalWithExtra encodes value and adds the extra fields it does not
+// define itself. value must not have a MarshalJSON method of its own.
+func marshalWithExtra(value interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	body, err := json.Marshal(value)
+	if err != nil || len(extra) == 0 {
+		return body, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	known := fieldNames(reflect.TypeOf(value))
+	for name, raw := range extra {
+		if !known[strings.ToLower(name)] {
+			fields[name] = raw
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// unmarshalWithExtra decodes data into value and keeps the fields value does
+// not define in extra. value must not have an UnmarshalJSON method of its own.
+func unmarshalWithExtra(data []byte, value interface{}, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, value); err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	known := fieldNames(reflect.TypeOf(value).Elem())
+	*extra = nil
+	for name, raw := range fields {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		if *extra == nil {
+			*extra = map[string]json.RawMessage{}
+		}
+		(*extra)[name] = raw
+	}
+	return nil
+}
+
+// fieldNames returns the JSON names of the fields of the struct type t, lower
+// cased since encoding/json matches names case-insensitively.
+func fieldNames(t reflect.Type) map[string]bool {
+	if names, ok := knownFields.Load(t); ok {
+		return names.(map[string]bool)
+	}
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	knownFields.Store(t, names)
+	return names
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"github.com/pariz/gountries"
+	"github.com/pborman/uuid"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	maxNames            = 4
+	maxAlternativeNames = 3
+	maxNameLength       = 140
+)
+
+var (
+	bicPattern = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+	countriesOnce sync.Once
+	countries     *gountries.Query
+	currencies    map[string]bool
+)
+
+// FieldError describes why a single field of a request was rejected. Field is
+// the JSON path of the field, e.g. "attributes.country".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every field a request was rejected for.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Field+" "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (v *ValidationErrors) add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// SetDefaults fills in the fields form3 needs but callers may leave out: the
+// resource type and a random id.
+func (a *AccountData) SetDefaults() {
+	if len(a.Type) == 0 {
+		a.Type = AccountType
+	}
+	if len(a.ID) == 0 {
+		a.ID = uuid.New()
+	}
+}
+
+// Validate checks the account locally against the rules form3 applies on
+// creation. It returns ValidationErrors listing every offending field, or nil.
+func (a AccountData) Validate() error {
+	var errs ValidationErrors
+
+	validateUUID(&errs, "id", a.ID)
+	validateUUID(&errs, "organisation_id", a.OrganisationID)
+	if len(a.Type) == 0 {
+		errs.add("type", "is required")
+	} else if a.Type != AccountType {
+		errs.add("type", "must be "+strconv.Quote(string(AccountType)))
+	}
+	if a.Attributes == nil {
+		errs.add("attributes", "is required")
+	} else {
+		a.Attributes.validate(&errs)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateUpdate checks the attributes of a partial update. Only the fields
+// that are set are checked, since the others are left as they are.
+func (a AccountAttributes) ValidateUpdate() error {
+	var errs ValidationErrors
+
+	if a.Country != nil && !isCountry(*a.Country) {
+		errs.add("attributes.country", "must be an ISO 3166-1 alpha-2 country code")
+	}
+	a.validateFormats(&errs)
+	validateNames(&errs, "attributes.name", a.Name, 0, maxNames)
+	validateNames(&errs, "attributes.alternative_names", a.AlternativeNames, 0, maxAlternativeNames)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (a AccountAttributes) validate(errs *ValidationErrors) {
+	if a.Country == nil || len(*a.Country) == 0 {
+		errs.add("attributes.country", "is required")
+	} else if !isCountry(*a.Country) {
+		errs.add("attributes.country", "must be an ISO 3166-1 alpha-2 country code")
+	} else {
+		a.validateCountryRules(errs, *a.Country)
+	}
+	a.validateFormats(errs)
+	validateNames(errs, "attributes.name", a.Name, 1, maxNames)
+	validateNames(errs, "attributes.alternative_names", a.AlternativeNames, 0, maxAlternativeNames)
+}
+
+// validateFormats checks the fields whose format does not depend on the
+// country of the account.
+func (a AccountAttributes) validateFormats(errs *ValidationErrors) {
+	if a.AccountClassification != nil && !a.AccountClassification.Valid() {
+		errs.add("attributes.account_classification", "must be one of "+strings.Join(accountClassifications, ", "))
+	}
+	if a.Status != nil && !a.Status.Valid() {
+		errs.add("attributes.status", "must be one of "+strings.Join(accountStatuses, ", "))
+	}
+	if len(a.BankIDCode) != 0 && !a.BankIDCode.Valid() {
+		errs.add("attributes.bank_id_code", "must be one of "+strings.Join(bankIDCodes, ", "))
+	}
+	if len(a.BaseCurrency) != 0 && !isCurrency(a.BaseCurrency) {
+		errs.add("attributes.base_currency", "must be an ISO 4217 currency code")
+	}
+	if len(a.Bic) != 0 && !bicPattern.MatchString(a.Bic) {
+		errs.add("attributes.bic", "must be an 8 or 11 character SWIFT BIC")
+	}
+	if len(a.SecondaryIdentification) > maxNameLength {
+		errs.add("attributes.secondary_identification", "must be at most "+strconv.Itoa(maxNameLength)+" characters")
+	}
+}
+
+func validateUUID(errs *ValidationErrors, field, value string) {
+	if len(value) == 0 {
+		errs.add(field, "is required")
+	} else if uuid.Parse(value) == nil {
+		errs.add(field, "must be a valid uuid")
+	}
+}
+
+func validateNames(errs *ValidationErrors, field string, names []string, min, max int) {
+	if len(names) < min {
+		errs.add(field, "is required")
+		return
+	}
+	if len(names) > max {
+		errs.add(field, "must have at most "+strconv.Itoa(max)+" entries")
+	}
+	for i, name := range names {
+		entry := field + "[" + strconv.Itoa(i) + "]"
+		if len(strings.TrimSpace(name)) == 0 {
+			errs.add(entry, "must not be blank")
+		} else if len([]rune(name)) > maxNameLength {
+			errs.add(entry, "must be at most "+strconv.Itoa(maxNameLength)+" characters")
+		}
+	}
+}
+
+// loadCountries reads the gountries data set once, on first use, and derives
+// the known currencies from it.
+func loadCountries() {
+	countriesOnce.Do(func() {
+		countries = gountries.New()
+		// Codes introduced after the gountries data set was published.
+		currencies = map[string]bool{"BYN": true, "MRU": true, "SLE": true, "STN": true, "VES": true}
+		for _, country := range countries.FindAllCountries() {
+			for _, currency := range country.Currencies {
+				currencies[currency] = true
+			}
+		}
+	})
+}
+
+func isCountry(code string) bool {
+	if len(code) != 2 || strings.ToUpper(code) != code {
+		return false
+	}
+	loadCountries()
+	_, err := countries.FindCountryByAlpha(code)
+	return err == nil
+}
+
+func isCurrency(code string) bool {
+	loadCountries()
+	return currencies[code]
+}
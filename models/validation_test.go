@@ -0,0 +1,179 @@
+package models_test
+
+import (
+	"form3-interview/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_accountValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		mutate   func(account *models.AccountData)
+		expected models.ValidationErrors
+	}{
+		{
+			name:     "valid account",
+			mutate:   func(account *models.AccountData) {},
+			expected: nil,
+		},
+		{
+			name: "missing id, organisation_id, type and attributes",
+			mutate: func(account *models.AccountData) {
+				*account = models.AccountData{}
+			},
+			expected: models.ValidationErrors{
+				{Field: "id", Message: "is required"},
+				{Field: "organisation_id", Message: "is required"},
+				{Field: "type", Message: "is required"},
+				{Field: "attributes", Message: "is required"},
+			},
+		},
+		{
+			name: "ids are not uuids and type is unknown",
+			mutate: func(account *models.AccountData) {
+				account.ID = "1234"
+				account.OrganisationID = "eb0bd6f5-c3f5"
+				account.Type = "account"
+			},
+			expected: models.ValidationErrors{
+				{Field: "id", Message: "must be a valid uuid"},
+				{Field: "organisation_id", Message: "must be a valid uuid"},
+				{Field: "type", Message: "must be \"accounts\""},
+			},
+		},
+		{
+			name: "missing country",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Country = nil
+			},
+			expected: models.ValidationErrors{{Field: "attributes.country", Message: "is required"}},
+		},
+		{
+			name: "unknown country",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Country = stringPtr("XX")
+			},
+			expected: models.ValidationErrors{{Field: "attributes.country", Message: "must be an ISO 3166-1 alpha-2 country code"}},
+		},
+		{
+			name: "lower case country",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Country = stringPtr("gb")
+			},
+			expected: models.ValidationErrors{{Field: "attributes.country", Message: "must be an ISO 3166-1 alpha-2 country code"}},
+		},
+		{
+			name: "unknown currency",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.BaseCurrency = "GBX"
+			},
+			expected: models.ValidationErrors{{Field: "attributes.base_currency", Message: "must be an ISO 4217 currency code"}},
+		},
+		{
+			name: "11 character BIC",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Bic = "NWBKGB22XXX"
+			},
+			expected: nil,
+		},
+		{
+			name: "malformed BIC",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Bic = "NWBK22"
+			},
+			expected: models.ValidationErrors{{Field: "attributes.bic", Message: "must be an 8 or 11 character SWIFT BIC"}},
+		},
+		{
+			name: "unknown classification, status and bank id code",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.AccountClassification = models.AccountClassification("personal").Ptr()
+				account.Attributes.Status = models.AccountStatus("closed").Ptr()
+				account.Attributes.BankIDCode = "GBSC"
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.bank_id_code", Message: "must be GBDSC for GB"},
+				{Field: "attributes.account_classification", Message: "must be one of Personal, Business"},
+				{Field: "attributes.status", Message: "must be one of pending, confirmed, failed"},
+				{Field: "attributes.bank_id_code", Message: "must be one of AUBSB, BE, CACPA, CHBCC, DEBLZ, ESNCC, FR, GBDSC, GRBIC, HKNCC, ITNCC, LULUX, PLKNR, PTNCC, USABA"},
+			},
+		},
+		{
+			name: "missing name",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Name = nil
+			},
+			expected: models.ValidationErrors{{Field: "attributes.name", Message: "is required"}},
+		},
+		{
+			name: "too many and too long names",
+			mutate: func(account *models.AccountData) {
+				account.Attributes.Name = []string{"a", "b", "c", "d", strings.Repeat("e", 141)}
+				account.Attributes.AlternativeNames = []string{"a", "b", "c", " "}
+			},
+			expected: models.ValidationErrors{
+				{Field: "attributes.name", Message: "must have at most 4 entries"},
+				{Field: "attributes.name[4]", Message: "must be at most 140 characters"},
+				{Field: "attributes.alternative_names", Message: "must have at most 3 entries"},
+				{Field: "attributes.alternative_names[3]", Message: "must not be blank"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			account := validAccount()
+			test.mutate(&account)
+
+			err := account.Validate()
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErrors models.ValidationErrors
+			assert.True(t, errors.As(err, &validationErrors))
+			assert.Equal(t, test.expected, validationErrors)
+		})
+	}
+}
+
+func Test_accountSetDefaults(t *testing.T) {
+	t.Parallel()
+
+	account := models.AccountData{}
+	account.SetDefaults()
+	assert.Equal(t, models.AccountType, account.Type)
+	assert.Len(t, account.ID, 36)
+
+	account = models.AccountData{ID: "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", Type: "other"}
+	account.SetDefaults()
+	assert.Equal(t, models.ResourceType("other"), account.Type)
+	assert.Equal(t, "cb1e2074-1056-4b27-b4e0-ed9f0c46b066", account.ID)
+}
+
+func validAccount() models.AccountData {
+	return models.AccountData{
+		ID:             "cb1e2074-1056-4b27-b4e0-ed9f0c46b066",
+		OrganisationID: "eb0bd6f5-c3f5-44b2-b677-acd23cdde73c",
+		Type:           "accounts",
+		Attributes: &models.AccountAttributes{
+			BankID:           "400300",
+			BankIDCode:       "GBDSC",
+			BaseCurrency:     "GBP",
+			Bic:              "NWBKGB22",
+			Country:          stringPtr("GB"),
+			Name:             []string{"Samantha Holder"},
+			AlternativeNames: []string{"Sam Holder"},
+		},
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
@@ -0,0 +1,228 @@
+// Package signing signs HTTP requests the way the form3 API expects, following
+// the HTTP Signatures draft: a Date header, a SHA-256 Digest of the body and a
+// signature of both, the request target and the host, made with an RSA or
+// ECDSA key. It also verifies such signatures, for stand-ins of the API.
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmRSA   = "rsa-sha256"
+	AlgorithmECDSA = "ecdsa-sha256"
+
+	// RequestTarget stands for the method and path of the request in the list
+	// of signed headers.
+	RequestTarget = "(request-target)"
+)
+
+// DefaultHeaders are the headers signed when a Signer lists none.
+var DefaultHeaders = []string{RequestTarget, "host", "date", "digest"}
+
+// Signer signs requests with the private key identified by KeyID. It is safe
+// for concurrent use.
+type Signer struct {
+	KeyID string
+	Key   crypto.Signer
+	// Headers are the headers signed, in order; nil signs DefaultHeaders.
+	Headers []string
+	// SignatureHeader is the header carrying the signature: "Authorization",
+	// the default, prefixes it with "Signature ", "Signature" carries it as is.
+	SignatureHeader string
+}
+
+// NewSigner returns a Signer of the key identified by keyID, loaded from the
+// PEM file at path.
+func NewSigner(keyID, path string) (*Signer, error) {
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{KeyID: keyID, Key: key}, nil
+}
+
+// Sign sets the Date and Digest headers of req and signs it. The body is read
+// to compute its digest and left for the request to be sent.
+func (s *Signer) Sign(req *http.Request) error {
+	algorithm, err := algorithmOf(s.Key.Public())
+	if err != nil {
+		return err
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return errors.Wrap(err, "Could not read request body")
+	}
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", digest(body))
+
+	headers := s.Headers
+	if len(headers) == 0 {
+		headers = DefaultHeaders
+	}
+	signingString, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(signingString))
+	signature, err := s.Key.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	if err != nil {
+		return errors.Wrap(err, "Could not sign request")
+	}
+
+	value := `keyId="` + s.KeyID + `",algorithm="` + algorithm + `",headers="` + strings.ToLower(strings.Join(headers, " ")) +
+		`",signature="` + base64.StdEncoding.EncodeToString(signature) + `"`
+	if strings.EqualFold(s.SignatureHeader, "Signature") {
+		req.Header.Set("Signature", value)
+	} else {
+		req.Header.Set("Authorization", "Signature "+value)
+	}
+	return nil
+}
+
+// signingString lists the headers of req, lowercased, one per line as
+// "name: value", the request target as "(request-target): method path".
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, header := range headers {
+		header = strings.ToLower(header)
+		switch header {
+		case RequestTarget:
+			lines = append(lines, header+": "+strings.ToLower(req.Method)+" "+req.URL.RequestURI())
+		case "host":
+			host := req.Host
+			if len(host) == 0 {
+				host = req.URL.Host
+			}
+			lines = append(lines, header+": "+host)
+		default:
+			values := req.Header.Values(header)
+			if len(values) == 0 {
+				return "", errors.Errorf("Missing signed header %q", header)
+			}
+			lines = append(lines, header+": "+strings.Join(values, ", "))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// readBody returns the body of req, leaving req with an unread copy of it.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if req.GetBody == nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return body, nil
+}
+
+func algorithmOf(key crypto.PublicKey) (string, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRSA, nil
+	case *ecdsa.PublicKey:
+		return AlgorithmECDSA, nil
+	}
+	return "", errors.Errorf("Unsupported key type %T, expected RSA or ECDSA", key)
+}
+
+// LoadPrivateKey reads an RSA or ECDSA private key from a PEM file, in PKCS #1,
+// SEC 1 or PKCS #8 form.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("Unexpected %q block in %q, expected a private key", block.Type, path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not parse private key in %q", path)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("Unsupported private key type %T in %q", key, path)
+	}
+	if _, err = algorithmOf(signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// LoadPublicKey reads an RSA or ECDSA public key from a PEM file, either as a
+// PKIX public key or as the key of a certificate.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = certificate.PublicKey
+		}
+	default:
+		return nil, errors.Errorf("Unexpected %q block in %q, expected a public key", block.Type, path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not parse public key in %q", path)
+	}
+	if _, err = algorithmOf(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read key file")
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.Errorf("No PEM block found in %q", path)
+	}
+	return block, nil
+}
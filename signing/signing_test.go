@@ -0,0 +1,195 @@
+package signing_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"form3-interview/signing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, blockType string, bytes []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600))
+	return path
+}
+
+// keyFiles writes a new key of each supported kind as PEM files.
+func keyFiles(t *testing.T) map[string][2]string {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ecdsaPrivate, err := x509.MarshalECPrivateKey(ecdsaKey)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	ecdsaPublic, err := x509.MarshalPKIXPublicKey(&ecdsaKey.PublicKey)
+	require.NoError(t, err)
+
+	return map[string][2]string{
+		"rsa pkcs1": {writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), writePEM(t, "PUBLIC KEY", rsaPublic)},
+		"rsa pkcs8": {writePEM(t, "PRIVATE KEY", pkcs8), writePEM(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))},
+		"ecdsa":     {writePEM(t, "EC PRIVATE KEY", ecdsaPrivate), writePEM(t, "PUBLIC KEY", ecdsaPublic)},
+	}
+}
+
+func Test_signAndVerify(t *testing.T) {
+	t.Parallel()
+
+	for name, files := range keyFiles(t) {
+		files := files
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			signer, err := signing.NewSigner("key-1", files[0])
+			require.NoError(t, err)
+			publicKey, err := signing.LoadPublicKey(files[1])
+			require.NoError(t, err)
+			verifier := &signing.Verifier{Keys: map[string]crypto.PublicKey{"key-1": publicKey}}
+
+			for _, header := range []string{"", "Signature"} {
+				signer.SignatureHeader = header
+				req := httptest.NewRequest("POST", "http://api.form3.tech/v1/organisation/accounts?x=1", strings.NewReader(`{"data":{}}`))
+				require.NoError(t, signer.Sign(req))
+
+				keyID, err := verifier.Verify(req)
+				assert.NoError(t, err)
+				assert.Equal(t, "key-1", keyID)
+				body, _ := ioutil.ReadAll(req.Body)
+				assert.Equal(t, `{"data":{}}`, string(body), "the body is left to be read")
+			}
+		})
+	}
+}
+
+func Test_verifyRejects(t *testing.T) {
+	t.Parallel()
+
+	files := keyFiles(t)["ecdsa"]
+	signer, err := signing.NewSigner("key-1", files[0])
+	require.NoError(t, err)
+	publicKey, err := signing.LoadPublicKey(files[1])
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		signer *signing.Signer
+		tamper func(req *http.Request)
+		err    string
+		// errPattern matches errors whose exact text depends on timing.
+		errPattern string
+	}{
+		{
+			name:   "unsigned request",
+			signer: &signing.Signer{},
+			err:    "request is not signed: invalid signature",
+		},
+		{
+			name:   "tampered body",
+			tamper: func(req *http.Request) { req.Body = ioutil.NopCloser(strings.NewReader(`{"data":{"id":"x"}}`)) },
+			err:    "digest does not match body: invalid signature",
+		},
+		{
+			name:   "tampered path",
+			tamper: func(req *http.Request) { req.URL.Path = "/v1/organisation/accounts/other" },
+			err:    "signature does not match: invalid signature",
+		},
+		{
+			name:   "unknown key",
+			signer: &signing.Signer{KeyID: "key-2", Key: signer.Key},
+			err:    `unknown key id "key-2": invalid signature`,
+		},
+		{
+			name:   "header left unsigned",
+			signer: &signing.Signer{KeyID: "key-1", Key: signer.Key, Headers: []string{"date"}},
+			err:    `header "(request-target)" is not signed: invalid signature`,
+		},
+		{
+			name: "stale date",
+			tamper: func(req *http.Request) {
+				req.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+			},
+			errPattern: `^Date is 1h0m\d+s old: invalid signature$`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("POST", "http://api.form3.tech/v1/organisation/accounts", strings.NewReader(`{"data":{}}`))
+			if test.signer == nil || test.signer.Key != nil {
+				requestSigner := signer
+				if test.signer != nil {
+					requestSigner = test.signer
+				}
+				require.NoError(t, requestSigner.Sign(req))
+			}
+			if test.tamper != nil {
+				test.tamper(req)
+			}
+
+			verifier := &signing.Verifier{Keys: map[string]crypto.PublicKey{"key-1": publicKey}}
+			_, err := verifier.Verify(req)
+			assert.True(t, errors.Is(err, signing.ErrInvalidSignature))
+			if len(test.errPattern) != 0 {
+				assert.Regexp(t, test.errPattern, err.Error())
+				return
+			}
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func Test_verifierMiddleware(t *testing.T) {
+	t.Parallel()
+
+	files := keyFiles(t)["rsa pkcs1"]
+	signer, err := signing.NewSigner("key-1", files[0])
+	require.NoError(t, err)
+	publicKey, err := signing.LoadPublicKey(files[1])
+	require.NoError(t, err)
+	handler := (&signing.Verifier{Keys: map[string]crypto.PublicKey{"key-1": publicKey}}).Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+	signed := httptest.NewRequest("GET", "http://api.form3.tech/v1/health", nil)
+	require.NoError(t, signer.Sign(signed))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, signed)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://api.form3.tech/v1/health", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func Test_loadKeyErrors(t *testing.T) {
+	t.Parallel()
+
+	notPEM := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, ioutil.WriteFile(notPEM, []byte("not a key"), 0600))
+	publicKey := keyFiles(t)["ecdsa"][1]
+
+	_, err := signing.LoadPrivateKey(notPEM)
+	assert.EqualError(t, err, `No PEM block found in "`+notPEM+`"`)
+	_, err = signing.LoadPrivateKey(publicKey)
+	assert.EqualError(t, err, `Unexpected "PUBLIC KEY" block in "`+publicKey+`", expected a private key`)
+	_, err = signing.LoadPrivateKey(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
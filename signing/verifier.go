@@ -0,0 +1,168 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is wrapped by every error Verify fails with.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Verifier checks the signatures of requests made by a Signer. It is safe for
+// concurrent use once set up.
+type Verifier struct {
+	// Keys holds the public key of each key id.
+	Keys map[string]crypto.PublicKey
+	// Required are the headers that must be signed; nil requires
+	// DefaultHeaders.
+	Required []string
+	// MaxSkew is how far the Date of a request may be from now; 0 allows five
+	// minutes.
+	MaxSkew time.Duration
+}
+
+// Verify checks that req is signed by one of the keys of the verifier, that its
+// Digest matches its body and that its Date is recent. It returns the id of the
+// key req is signed with.
+func (v *Verifier) Verify(req *http.Request) (keyID string, err error) {
+	params, err := signatureParams(req)
+	if err != nil {
+		return "", err
+	}
+	keyID = params["keyid"]
+	key, ok := v.Keys[keyID]
+	if !ok {
+		return keyID, invalid("unknown key id %q", keyID)
+	}
+	algorithm, err := algorithmOf(key)
+	if err != nil {
+		return keyID, err
+	}
+	if declared, ok := params["algorithm"]; ok && declared != algorithm && declared != "hs2019" {
+		return keyID, invalid("algorithm %q does not match key %q", declared, keyID)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := v.Required
+	if len(required) == 0 {
+		required = DefaultHeaders
+	}
+	for _, header := range required {
+		if !contains(headers, strings.ToLower(header)) {
+			return keyID, invalid("header %q is not signed", header)
+		}
+	}
+
+	if err = v.checkDate(req); err != nil {
+		return keyID, err
+	}
+	if len(req.Header.Get("Digest")) != 0 || contains(headers, "digest") {
+		body, err := readBody(req)
+		if err != nil {
+			return keyID, errors.Wrap(err, "Could not read request body")
+		}
+		if req.Header.Get("Digest") != digest(body) {
+			return keyID, invalid("digest does not match body")
+		}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return keyID, invalid("signature is not base64")
+	}
+	signingString, err := signingString(req, headers)
+	if err != nil {
+		return keyID, invalid("%s", err)
+	}
+	hashed := sha256.Sum256([]byte(signingString))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature) == nil
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, hashed[:], signature)
+	}
+	if !ok {
+		return keyID, invalid("signature does not match")
+	}
+	return keyID, nil
+}
+
+// Middleware rejects the requests that fail Verify with 401 Unauthorized.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *Verifier) checkDate(req *http.Request) error {
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return invalid("missing or malformed Date header")
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = 5 * time.Minute
+	}
+	skew := time.Since(date)
+	if skew > maxSkew {
+		return invalid("Date is %s old", skew.Round(time.Second))
+	}
+	if skew < -maxSkew {
+		return invalid("Date is %s in the future", (-skew).Round(time.Second))
+	}
+	return nil
+}
+
+// signatureParams parses the parameters of the signature of req, from its
+// Signature header or its Authorization one, keyed in lower case.
+func signatureParams(req *http.Request) (map[string]string, error) {
+	value := req.Header.Get("Signature")
+	if len(value) == 0 {
+		authorization := req.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Signature ") {
+			return nil, invalid("request is not signed")
+		}
+		value = strings.TrimPrefix(authorization, "Signature ")
+	}
+	params := map[string]string{}
+	for _, param := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(parts) != 2 {
+			return nil, invalid("malformed signature parameter %q", param)
+		}
+		params[strings.ToLower(parts[0])] = strings.Trim(parts[1], `"`)
+	}
+	for _, name := range []string{"keyid", "signature"} {
+		if len(params[name]) == 0 {
+			return nil, invalid("missing %q signature parameter", name)
+		}
+	}
+	return params, nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return errors.Wrapf(ErrInvalidSignature, format, args...)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// Package tracing sets up OpenTelemetry tracing and carries W3C trace context
+// across the proxy, from the requests it serves to the calls it makes to form3.
+package tracing
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+)
+
+const instrumentationName = "form3-interview"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+)
+
+// Attributes recorded on the spans of account requests.
+const (
+	AccountIDKey      = attribute.Key("form3.account_id")
+	OrganisationIDKey = attribute.Key("form3.organisation_id")
+	ErrorKindKey      = attribute.Key("form3.error_kind")
+)
+
+var propagator = propagation.TraceContext{}
+
+// NewExporter returns the span exporter called name: ExporterStdout writes
+// spans to stdout as json, ExporterNone or "" returns nil so that spans are
+// not exported at all.
+func NewExporter(name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterNone, "":
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	return nil, errors.Errorf("Unknown trace exporter %q, expected %q or %q", name, ExporterStdout, ExporterNone)
+}
+
+// NewProvider returns a tracer provider naming the service serviceName and
+// batching its spans to exporter. A nil exporter records spans without
+// exporting them, so trace context is still propagated.
+func NewProvider(serviceName string, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// Tracer returns the tracer of the proxy from provider, or a tracer that
+// records nothing when provider is nil.
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// Extract returns a copy of ctx carrying the trace context of the traceparent
+// header, if header has a valid one.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject writes the trace context of ctx into header as traceparent.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
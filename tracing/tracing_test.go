@@ -0,0 +1,56 @@
+package tracing_test
+
+import (
+	"context"
+	"form3-interview/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"testing"
+)
+
+func Test_newExporter(t *testing.T) {
+	t.Parallel()
+
+	exporter, err := tracing.NewExporter("")
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	exporter, err = tracing.NewExporter(tracing.ExporterStdout)
+	assert.NoError(t, err)
+	assert.NotNil(t, exporter)
+
+	_, err = tracing.NewExporter("jaeger")
+	assert.EqualError(t, err, "Unknown trace exporter \"jaeger\", expected \"stdout\" or \"none\"")
+}
+
+func Test_propagation(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider("test", exporter)
+
+	ctx, span := tracing.Tracer(provider).Start(context.Background(), "parent")
+	header := http.Header{}
+	tracing.Inject(ctx, header)
+	span.End()
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+
+	traceparent := header.Get("traceparent")
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", traceparent)
+
+	_, child := tracing.Tracer(provider).Start(tracing.Extract(context.Background(), header), "child")
+	child.End()
+	assert.Equal(t, span.SpanContext().TraceID(), child.SpanContext().TraceID())
+
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+	assert.Len(t, exporter.GetSpans(), 2)
+}
+
+func Test_tracerWithoutProvider(t *testing.T) {
+	t.Parallel()
+
+	_, span := tracing.Tracer(nil).Start(context.Background(), "nothing")
+	assert.False(t, span.IsRecording())
+	span.End()
+}